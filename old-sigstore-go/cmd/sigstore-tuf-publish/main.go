@@ -0,0 +1,171 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command sigstore-tuf-publish maintains the TUF repository of a private
+// Sigstore deployment. See package publish for the layout of the repository.
+//
+// The keys of each role are encrypted with the passphrase in the
+// SIGSTORE_TUF_<ROLE>_PASSPHRASE environment variable, or stored unencrypted
+// with -insecure-plaintext-keys.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/publish"
+)
+
+const usage = `Usage: sigstore-tuf-publish [flags] <command> [args]
+
+Commands:
+  init [-consistent-snapshot]   Creates a repository with a key for each role
+  add-trusted-root <file>       Stages trusted_root.json
+  add-signing-config <file>     Stages the signing configuration
+  rotate-key <role>             Stages a new key for a top-level role
+  publish                       Signs and publishes the staged changes
+  resign-timestamp [-every d]   Publishes a new timestamp, or one every d
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "sigstore-tuf-publish: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sigstore-tuf-publish", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	dir := fs.String("repo", ".", "directory of the repository")
+	plaintext := fs.Bool("insecure-plaintext-keys", false, "store the keys unencrypted")
+	var opts publish.Options
+	fs.DurationVar(&opts.RootExpires, "root-expires", 0, "validity of root metadata (default 365 days)")
+	fs.DurationVar(&opts.TargetsExpires, "targets-expires", 0, "validity of targets metadata (default 90 days)")
+	fs.DurationVar(&opts.SnapshotExpires, "snapshot-expires", 0, "validity of snapshot metadata (default 7 days)")
+	fs.DurationVar(&opts.TimestampExpires, "timestamp-expires", 0, "validity of timestamp metadata (default 1 day)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+	if !*plaintext {
+		opts.Passphrase = passphraseFromEnv
+	}
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+	cmdFlags := flag.NewFlagSet(command, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	switch command {
+	case "init":
+		consistentSnapshot := cmdFlags.Bool("consistent-snapshot", false, "publish versioned metadata and hash-prefixed targets")
+		if err := parseArgs(cmdFlags, args, 0); err != nil {
+			return err
+		}
+		_, err := publish.Init(*dir, *consistentSnapshot, &opts)
+		return err
+	case "add-trusted-root", "add-signing-config":
+		if err := parseArgs(cmdFlags, args, 1); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(cmdFlags.Arg(0))
+		if err != nil {
+			return err
+		}
+		r, err := publish.Open(*dir, &opts)
+		if err != nil {
+			return err
+		}
+		if command == "add-trusted-root" {
+			return r.AddTrustedRoot(b)
+		}
+		return r.AddSigningConfig(b)
+	case "rotate-key":
+		if err := parseArgs(cmdFlags, args, 1); err != nil {
+			return err
+		}
+		r, err := publish.Open(*dir, &opts)
+		if err != nil {
+			return err
+		}
+		return r.RotateKey(cmdFlags.Arg(0))
+	case "publish":
+		if err := parseArgs(cmdFlags, args, 0); err != nil {
+			return err
+		}
+		r, err := publish.Open(*dir, &opts)
+		if err != nil {
+			return err
+		}
+		return r.Publish()
+	case "resign-timestamp":
+		every := cmdFlags.Duration("every", 0, "keep re-signing the timestamp at this interval")
+		if err := parseArgs(cmdFlags, args, 0); err != nil {
+			return err
+		}
+		r, err := publish.Open(*dir, &opts)
+		if err != nil {
+			return err
+		}
+		if err := r.ResignTimestamp(); err != nil || *every == 0 {
+			return err
+		}
+		if err := r.ResignTimestamps(ctx, *every); !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// parseArgs parses the flags of a command, which takes n arguments.
+func parseArgs(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		return fmt.Errorf("%s takes %d arguments, got %d", fs.Name(), n, fs.NArg())
+	}
+	return nil
+}
+
+// passphraseFromEnv reads the passphrase of the keys of a role from the
+// environment.
+func passphraseFromEnv(role string, _, _ bool) ([]byte, error) {
+	name := fmt.Sprintf("SIGSTORE_TUF_%s_PASSPHRASE", strings.ToUpper(role))
+	passphrase, ok := os.LookupEnv(name)
+	if !ok || passphrase == "" {
+		return nil, fmt.Errorf("%s is not set, use -insecure-plaintext-keys to store keys unencrypted", name)
+	}
+	return []byte(passphrase), nil
+}
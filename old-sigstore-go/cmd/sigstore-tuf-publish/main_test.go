@@ -0,0 +1,112 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo")
+	trustedRoot := filepath.Join(t.TempDir(), "trusted_root.json")
+	if err := os.WriteFile(trustedRoot, tuftest.NewTrustedRootJSON(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIGSTORE_TUF_ROOT_PASSPHRASE", "root")
+	t.Setenv("SIGSTORE_TUF_TARGETS_PASSPHRASE", "targets")
+	t.Setenv("SIGSTORE_TUF_SNAPSHOT_PASSPHRASE", "snapshot")
+	t.Setenv("SIGSTORE_TUF_TIMESTAMP_PASSPHRASE", "timestamp")
+
+	for _, args := range [][]string{
+		{"-repo", dir, "init", "-consistent-snapshot"},
+		{"-repo", dir, "add-trusted-root", trustedRoot},
+		{"-repo", dir, "publish"},
+		{"-repo", dir, "rotate-key", "root"},
+		{"-repo", dir, "publish"},
+		{"-repo", dir, "-timestamp-expires", "1h", "resign-timestamp"},
+	} {
+		if err := run(context.Background(), args, io.Discard); err != nil {
+			t.Fatalf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	root, err := os.ReadFile(filepath.Join(dir, "repository", "1.root.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := tuf.NewSigstoreTufClient(&tuf.ClientOptions{CacheType: tuf.Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &tuf.RepositoryOptions{
+		Name:   "private",
+		Remote: fmt.Sprintf("file://%s/repository", dir),
+		Root:   root,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+
+	// The keys are encrypted.
+	t.Setenv("SIGSTORE_TUF_TIMESTAMP_PASSPHRASE", "")
+	if err := run(context.Background(), []string{"-repo", dir, "resign-timestamp"}, io.Discard); err == nil {
+		t.Error("resign-timestamp returned, expected error without passphrase")
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "no command",
+		},
+		{
+			name: "unknown command",
+			args: []string{"unknown"},
+		},
+		{
+			name: "missing argument",
+			args: []string{"rotate-key"},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"init", "-unknown"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			args := append([]string{"-insecure-plaintext-keys", "-repo", t.TempDir()}, tc.args...)
+			if err := run(context.Background(), args, io.Discard); err == nil {
+				t.Error("run returned, expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshInterval is the interval between background refreshes
+	// when CachingOptions.RefreshInterval is unset.
+	DefaultRefreshInterval = time.Hour
+	// DefaultMaxStaleness is how long a trusted root is served after it was
+	// fetched when CachingOptions.MaxStaleness is unset.
+	DefaultMaxStaleness = 24 * time.Hour
+)
+
+// CachingOptions configure a CachingTrustedRootProvider.
+type CachingOptions struct {
+	// RefreshInterval is the time between background refreshes.
+	// Default: DefaultRefreshInterval.
+	RefreshInterval time.Duration
+
+	// Jitter is the maximum random duration added to each refresh interval,
+	// so that many clients do not refresh in lockstep.
+	Jitter time.Duration
+
+	// MaxStaleness is how long the last successfully fetched trusted root
+	// keeps being served while refreshes fail. Once exceeded, GetTrustedRoot
+	// returns a StaleError. It must not be shorter than RefreshInterval.
+	// Default: DefaultMaxStaleness.
+	MaxStaleness time.Duration
+}
+
+// CachingTrustedRootProvider caches the trusted root of another provider and
+// refreshes it in the background, so that verifications do not wait on the
+// network. It is safe for concurrent use.
+type CachingTrustedRootProvider struct {
+	provider TrustedRootProvider
+	opts     CachingOptions
+
+	// now returns the current time, and is overridden in tests.
+	now func() time.Time
+	// rng draws the jitter. It is only used by the background refresh.
+	rng *rand.Rand
+
+	// refreshMu serializes refreshes, so that a slow fetch never replaces
+	// the result of a later one.
+	refreshMu sync.Mutex
+
+	mu      sync.RWMutex
+	root    *TrustedRoot
+	fetched time.Time
+	lastErr error
+
+	// cancel cancels the context of background refreshes.
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCachingTrustedRootProvider fetches the trusted root from provider, bound
+// to ctx, and starts refreshing it in the background. Close must be called to
+// stop the background refresh.
+func NewCachingTrustedRootProvider(ctx context.Context, provider TrustedRootProvider, opts CachingOptions) (*CachingTrustedRootProvider, error) {
+	if opts.RefreshInterval == 0 {
+		opts.RefreshInterval = DefaultRefreshInterval
+	}
+	if opts.MaxStaleness == 0 {
+		opts.MaxStaleness = DefaultMaxStaleness
+	}
+	if opts.RefreshInterval < 0 || opts.Jitter < 0 {
+		return nil, errors.New("refresh interval and jitter must not be negative")
+	}
+	if opts.MaxStaleness < opts.RefreshInterval {
+		return nil, fmt.Errorf("max staleness %s is shorter than refresh interval %s",
+			opts.MaxStaleness, opts.RefreshInterval)
+	}
+	c := &CachingTrustedRootProvider{
+		provider: provider,
+		opts:     opts,
+		now:      time.Now,
+		// #nosec G404 -- jitter does not need a cryptographic source.
+		rng:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		done: make(chan struct{}),
+	}
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	// Background refreshes outlive ctx, and are canceled by Close instead.
+	bgCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.run(bgCtx)
+	return c, nil
+}
+
+// GetTrustedRoot returns the cached trusted root without making any network
+// call. If refreshes have been failing for longer than the maximum staleness,
+// it returns a StaleError wrapping the last refresh error instead.
+func (c *CachingTrustedRootProvider) GetTrustedRoot(_ context.Context) (*TrustedRoot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if age := c.now().Sub(c.fetched); age > c.opts.MaxStaleness {
+		return nil, &StaleError{Age: age, MaxStaleness: c.opts.MaxStaleness, Err: c.lastErr}
+	}
+	return c.root, nil
+}
+
+// Refresh fetches the trusted root from the wrapped provider. On failure the
+// previously cached trusted root is kept, and the error is returned.
+// Concurrent calls are serialized.
+func (c *CachingTrustedRootProvider) Refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	tr, err := c.provider.GetTrustedRoot(ctx)
+	if err == nil && tr == nil {
+		err = errors.New("provider returned no trusted root")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.lastErr = err
+		return fmt.Errorf("refreshing trusted root: %w", err)
+	}
+	c.root = tr
+	c.fetched = c.now()
+	c.lastErr = nil
+	return nil
+}
+
+// Close stops the background refresh, canceling any refresh in progress.
+// The cached trusted root keeps being served.
+func (c *CachingTrustedRootProvider) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		<-c.done
+	})
+	return nil
+}
+
+func (c *CachingTrustedRootProvider) run(ctx context.Context) {
+	defer close(c.done)
+	timer := time.NewTimer(c.nextInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			// Errors are recorded and surface through GetTrustedRoot once
+			// the cached trusted root is too stale.
+			_ = c.Refresh(ctx)
+			timer.Reset(c.nextInterval())
+		}
+	}
+}
+
+func (c *CachingTrustedRootProvider) nextInterval() time.Duration {
+	if c.opts.Jitter <= 0 {
+		return c.opts.RefreshInterval
+	}
+	return c.opts.RefreshInterval + time.Duration(c.rng.Int63n(int64(c.opts.Jitter)))
+}
@@ -0,0 +1,255 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingProvider counts fetches and fails while err is set.
+type countingProvider struct {
+	mu    sync.Mutex
+	tr    *TrustedRoot
+	err   error
+	calls int
+}
+
+func (p *countingProvider) GetTrustedRoot(_ context.Context) (*TrustedRoot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.tr, nil
+}
+
+func (p *countingProvider) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *countingProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestNewCachingTrustedRootProvider(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    CachingOptions
+		fetch   error
+		noRoot  bool
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "negative jitter",
+			opts:    CachingOptions{Jitter: -time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "max staleness shorter than refresh interval",
+			opts:    CachingOptions{RefreshInterval: time.Hour, MaxStaleness: time.Minute},
+			wantErr: true,
+		},
+		{
+			name:    "initial fetch fails",
+			fetch:   errors.New("unavailable"),
+			wantErr: true,
+		},
+		{
+			name:    "provider returns no trusted root",
+			noRoot:  true,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := &countingProvider{tr: &TrustedRoot{}, err: tc.fetch}
+			if tc.noRoot {
+				p.tr = nil
+			}
+			c, err := NewCachingTrustedRootProvider(context.Background(), p, tc.opts)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("NewCachingTrustedRootProvider unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			defer c.Close()
+			if tc.wantErr {
+				t.Fatal("NewCachingTrustedRootProvider returned, expected error")
+			}
+		})
+	}
+}
+
+func TestCachingProviderServesStaleRoot(t *testing.T) {
+	tr := &TrustedRoot{}
+	p := &countingProvider{tr: tr}
+	c, err := NewCachingTrustedRootProvider(context.Background(), p, CachingOptions{
+		RefreshInterval: time.Hour,
+		MaxStaleness:    2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	now := time.Now()
+	c.mu.Lock()
+	c.now = func() time.Time { return now }
+	c.mu.Unlock()
+
+	refreshErr := errors.New("mirror down")
+	p.setErr(refreshErr)
+	if err := c.Refresh(context.Background()); !errors.Is(err, refreshErr) {
+		t.Fatalf("Refresh returned %v, expected %v", err, refreshErr)
+	}
+	if got, err := c.GetTrustedRoot(context.Background()); err != nil || got != tr {
+		t.Fatalf("GetTrustedRoot returned %v, %v, expected the last good root", got, err)
+	}
+
+	c.mu.Lock()
+	c.now = func() time.Time { return now.Add(3 * time.Hour) }
+	c.mu.Unlock()
+	_, err = c.GetTrustedRoot(context.Background())
+	var staleErr *StaleError
+	if !errors.As(err, &staleErr) || !errors.Is(err, refreshErr) {
+		t.Fatalf("GetTrustedRoot returned %v, expected StaleError wrapping %v", err, refreshErr)
+	}
+
+	// A successful refresh makes the provider usable again.
+	p.setErr(nil)
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTrustedRoot(context.Background()); err != nil {
+		t.Errorf("GetTrustedRoot unexpectedly returned an error after refresh: %v", err)
+	}
+}
+
+// blockingProvider returns a new trusted root on every call, and records how
+// many calls overlap.
+type blockingProvider struct {
+	mu         sync.Mutex
+	inFlight   int
+	maxFlight  int
+	lastServed *TrustedRoot
+}
+
+func (p *blockingProvider) GetTrustedRoot(_ context.Context) (*TrustedRoot, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxFlight {
+		p.maxFlight = p.inFlight
+	}
+	p.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+	p.lastServed = &TrustedRoot{}
+	return p.lastServed, nil
+}
+
+func TestCachingProviderConcurrentRefresh(t *testing.T) {
+	p := &blockingProvider{}
+	c, err := NewCachingTrustedRootProvider(context.Background(), p, CachingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Refresh(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p.maxFlight != 1 {
+		t.Errorf("expected refreshes to be serialized, got %d concurrent fetches", p.maxFlight)
+	}
+	got, err := c.GetTrustedRoot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != p.lastServed {
+		t.Error("GetTrustedRoot did not return the most recently fetched root")
+	}
+}
+
+func TestCachingProviderBackgroundRefresh(t *testing.T) {
+	p := &countingProvider{tr: &TrustedRoot{}}
+	c, err := NewCachingTrustedRootProvider(context.Background(), p, CachingOptions{
+		RefreshInterval: 5 * time.Millisecond,
+		Jitter:          5 * time.Millisecond,
+		MaxStaleness:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := c.GetTrustedRoot(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.callCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected background refreshes, got %d fetches", p.callCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	calls := p.callCount()
+	time.Sleep(20 * time.Millisecond)
+	if p.callCount() != calls {
+		t.Errorf("expected no refreshes after Close, got %d more", p.callCount()-calls)
+	}
+}
@@ -0,0 +1,159 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// TrustedRootSource is a named TrustedRootProvider. The name is recorded on
+// every trust anchor obtained from the provider.
+type TrustedRootSource struct {
+	Name     string
+	Provider TrustedRootProvider
+}
+
+// CompositeTrustedRootProvider merges the trusted roots of several providers
+// into a single logical trusted root, e.g. to trust the public-good Sigstore
+// instance alongside a private one.
+type CompositeTrustedRootProvider struct {
+	sources []TrustedRootSource
+}
+
+// NewCompositeTrustedRootProvider creates a provider merging the trusted
+// roots of the given sources. Source names must be unique.
+func NewCompositeTrustedRootProvider(sources ...TrustedRootSource) (*CompositeTrustedRootProvider, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("at least one trusted root source is required")
+	}
+	names := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		if src.Name == "" || src.Provider == nil {
+			return nil, errors.New("trusted root sources require a name and a provider")
+		}
+		if names[src.Name] {
+			return nil, fmt.Errorf("duplicate trusted root source %s", src.Name)
+		}
+		names[src.Name] = true
+	}
+	return &CompositeTrustedRootProvider{sources: sources}, nil
+}
+
+// GetTrustedRoot fetches the trusted root of every source and merges them.
+// Identical trust anchors served by several sources are deduplicated and
+// attributed to the first source. Anchors that share an identity but differ,
+// such as a log ID with different keys, fail the merge with a ConflictError.
+func (c *CompositeTrustedRootProvider) GetTrustedRoot(ctx context.Context) (*TrustedRoot, error) {
+	merged := &TrustedRoot{
+		transparencyLogs: make(map[string]*TransparencyLog),
+		ctLogs:           make(map[string]*TransparencyLog),
+	}
+	for _, src := range c.sources {
+		tr, err := src.Provider.GetTrustedRoot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting trusted root from %s: %w", src.Name, err)
+		}
+		if err := mergeLogs(merged.transparencyLogs, tr.transparencyLogs, "transparency log", src.Name); err != nil {
+			return nil, err
+		}
+		if err := mergeLogs(merged.ctLogs, tr.ctLogs, "CT log", src.Name); err != nil {
+			return nil, err
+		}
+		if merged.certificateAuthorities, err = mergeAuthorities(merged.certificateAuthorities,
+			tr.certificateAuthorities, "certificate authority", src.Name); err != nil {
+			return nil, err
+		}
+		if merged.timestampAuthorities, err = mergeAuthorities(merged.timestampAuthorities,
+			tr.timestampAuthorities, "timestamp authority", src.Name); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+func mergeLogs(dst, src map[string]*TransparencyLog, kind, source string) error {
+	for id, l := range src {
+		existing, ok := dst[id]
+		if !ok {
+			attributed := *l
+			attributed.Source = source
+			dst[id] = &attributed
+			continue
+		}
+		conflict := &ConflictError{Kind: kind, ID: id, Sources: []string{existing.Source, source}}
+		if err := cryptoutils.EqualKeys(existing.PublicKey, l.PublicKey); err != nil {
+			conflict.Reason = "different public keys"
+			return conflict
+		}
+		if !sameValidityPeriod(existing.ValidityPeriodStart, existing.ValidityPeriodEnd,
+			l.ValidityPeriodStart, l.ValidityPeriodEnd) {
+			conflict.Reason = "different validity periods"
+			return conflict
+		}
+	}
+	return nil
+}
+
+func mergeAuthorities(dst, src []*CertificateAuthority, kind, source string) ([]*CertificateAuthority, error) {
+	for _, ca := range src {
+		id := authorityID(ca)
+		var existing *CertificateAuthority
+		for _, d := range dst {
+			if authorityID(d) == id {
+				existing = d
+				break
+			}
+		}
+		if existing == nil {
+			attributed := *ca
+			attributed.Source = source
+			dst = append(dst, &attributed)
+			continue
+		}
+		if !sameValidityPeriod(existing.ValidityPeriodStart, existing.ValidityPeriodEnd,
+			ca.ValidityPeriodStart, ca.ValidityPeriodEnd) {
+			return nil, &ConflictError{
+				Kind:    kind,
+				ID:      id,
+				Sources: []string{existing.Source, source},
+				Reason:  "different validity periods",
+			}
+		}
+	}
+	return dst, nil
+}
+
+// authorityID identifies a certificate authority by the hex-encoded SHA-256
+// digest of its certificate chain, from the root towards the leaf.
+func authorityID(ca *CertificateAuthority) string {
+	var chain bytes.Buffer
+	chain.Write(ca.Root.Raw)
+	for i := len(ca.Intermediates) - 1; i >= 0; i-- {
+		chain.Write(ca.Intermediates[i].Raw)
+	}
+	if ca.Leaf != nil {
+		chain.Write(ca.Leaf.Raw)
+	}
+	digest := sha256.Sum256(chain.Bytes())
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,143 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+)
+
+type staticProvider struct {
+	tr  *TrustedRoot
+	err error
+}
+
+func (p *staticProvider) GetTrustedRoot(_ context.Context) (*TrustedRoot, error) {
+	return p.tr, p.err
+}
+
+func newStaticProvider(t *testing.T, pb *prototrustroot.TrustedRoot) *staticProvider {
+	t.Helper()
+	tr, err := NewTrustedRootFromProtobuf(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &staticProvider{tr: tr}
+}
+
+func TestNewCompositeTrustedRootProvider(t *testing.T) {
+	p := &staticProvider{}
+	if _, err := NewCompositeTrustedRootProvider(); err == nil {
+		t.Error("NewCompositeTrustedRootProvider returned, expected error for no sources")
+	}
+	if _, err := NewCompositeTrustedRootProvider(TrustedRootSource{Provider: p}); err == nil {
+		t.Error("NewCompositeTrustedRootProvider returned, expected error for unnamed source")
+	}
+	if _, err := NewCompositeTrustedRootProvider(
+		TrustedRootSource{Name: "a", Provider: p}, TrustedRootSource{Name: "a", Provider: p}); err == nil {
+		t.Error("NewCompositeTrustedRootProvider returned, expected error for duplicate names")
+	}
+}
+
+func TestCompositeGetTrustedRoot(t *testing.T) {
+	public := newTestTrustedRoot(t)
+	private := &prototrustroot.TrustedRoot{
+		MediaType: TrustedRootMediaType01,
+		Tlogs: []*prototrustroot.TransparencyLogInstance{
+			newTestLog(t, "https://rekor.internal", testStart, time.Time{}),
+			// Also served by the public instance.
+			public.Tlogs[1],
+		},
+		CertificateAuthorities: []*prototrustroot.CertificateAuthority{
+			newTestCA(t, "https://fulcio.internal", testStart, time.Time{}),
+		},
+	}
+	c, err := NewCompositeTrustedRootProvider(
+		TrustedRootSource{Name: "public-good", Provider: newStaticProvider(t, public)},
+		TrustedRootSource{Name: "internal", Provider: newStaticProvider(t, private)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := c.GetTrustedRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if len(tr.TransparencyLogs()) != 3 {
+		t.Errorf("expected 3 transparency logs, got %d", len(tr.TransparencyLogs()))
+	}
+	if got := tr.TransparencyLogs()[logIDOf(private.Tlogs[0])].Source; got != "internal" {
+		t.Errorf("expected internal log to come from internal, got %q", got)
+	}
+	if got := tr.TransparencyLogs()[logIDOf(public.Tlogs[1])].Source; got != "public-good" {
+		t.Errorf("expected shared log to be attributed to public-good, got %q", got)
+	}
+	if len(tr.CertificateAuthorities()) != 3 {
+		t.Fatalf("expected 3 certificate authorities, got %d", len(tr.CertificateAuthorities()))
+	}
+	if got := tr.CertificateAuthorities()[2].Source; got != "internal" {
+		t.Errorf("expected internal CA to come from internal, got %q", got)
+	}
+	// The merged root must not alter the roots it was built from.
+	src, _ := c.sources[1].Provider.GetTrustedRoot(context.Background())
+	if got := src.TransparencyLogs()[logIDOf(private.Tlogs[0])].Source; got != "" {
+		t.Errorf("expected source trusted root to be unmodified, got source %q", got)
+	}
+}
+
+func TestCompositeGetTrustedRootConflict(t *testing.T) {
+	public := newTestTrustedRoot(t)
+	impostor := newTestLog(t, "https://rekor.internal", testStart, time.Time{})
+	impostor.LogId = &protocommon.LogId{KeyId: public.Tlogs[0].GetLogId().GetKeyId()}
+	private := &prototrustroot.TrustedRoot{
+		MediaType: TrustedRootMediaType01,
+		Tlogs:     []*prototrustroot.TransparencyLogInstance{impostor},
+	}
+	c, err := NewCompositeTrustedRootProvider(
+		TrustedRootSource{Name: "public-good", Provider: newStaticProvider(t, public)},
+		TrustedRootSource{Name: "internal", Provider: newStaticProvider(t, private)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetTrustedRoot(context.Background())
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("GetTrustedRoot returned %v, expected ConflictError", err)
+	}
+	if conflictErr.ID != logIDOf(public.Tlogs[0]) {
+		t.Errorf("expected conflict on %s, got %s", logIDOf(public.Tlogs[0]), conflictErr.ID)
+	}
+}
+
+func TestCompositeGetTrustedRootSourceError(t *testing.T) {
+	wantErr := errors.New("unavailable")
+	c, err := NewCompositeTrustedRootProvider(
+		TrustedRootSource{Name: "public-good", Provider: newStaticProvider(t, newTestTrustedRoot(t))},
+		TrustedRootSource{Name: "internal", Provider: &staticProvider{err: wantErr}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTrustedRoot(context.Background()); !errors.Is(err, wantErr) {
+		t.Errorf("GetTrustedRoot returned %v, expected %v", err, wantErr)
+	}
+}
@@ -0,0 +1,257 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// ChangeKind is the kind of change made to a trust anchor.
+type ChangeKind string
+
+// Kinds of changes reported in a TrustedRootDiff.
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Categories of trust anchors reported in a TrustedRootDiff.
+const (
+	CategoryTransparencyLog      = "tlog"
+	CategoryCTLog                = "ctlog"
+	CategoryCertificateAuthority = "certificate_authority"
+	CategoryTimestampAuthority   = "timestamp_authority"
+)
+
+// ValidityPeriod is the validity period of a trust anchor. A nil End means
+// the anchor is still in use.
+type ValidityPeriod struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+func newValidityPeriod(start, end time.Time) *ValidityPeriod {
+	v := &ValidityPeriod{Start: start}
+	if !end.IsZero() {
+		v.End = &end
+	}
+	return v
+}
+
+func (v *ValidityPeriod) String() string {
+	end := "unbounded"
+	if v.End != nil {
+		end = formatValidityTime(*v.End)
+	}
+	return formatValidityTime(v.Start) + " to " + end
+}
+
+// Change describes a trust anchor that was added, removed or modified.
+type Change struct {
+	Kind     ChangeKind `json:"kind"`
+	Category string     `json:"category"`
+	// ID is the hex-encoded log ID of a log, or the hex-encoded SHA-256 of
+	// the certificate chain of an authority.
+	ID string `json:"id"`
+	// URL is the base URL of a log or the URI of an authority.
+	URL string `json:"url,omitempty"`
+	// Fields lists the fields of a modified anchor that changed.
+	Fields []string `json:"fields,omitempty"`
+	// OldValidity and NewValidity are the validity periods before and after
+	// the change, when applicable.
+	OldValidity *ValidityPeriod `json:"old_validity,omitempty"`
+	NewValidity *ValidityPeriod `json:"new_validity,omitempty"`
+}
+
+func (c Change) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", c.Category, c.Kind, c.ID)
+	if c.URL != "" {
+		fmt.Fprintf(&b, " (%s)", c.URL)
+	}
+	switch c.Kind {
+	case ChangeAdded:
+		fmt.Fprintf(&b, ", valid %s", c.NewValidity)
+	case ChangeRemoved:
+		fmt.Fprintf(&b, ", was valid %s", c.OldValidity)
+	case ChangeModified:
+		fmt.Fprintf(&b, ": %s changed", strings.Join(c.Fields, ", "))
+		if c.OldValidity.String() != c.NewValidity.String() {
+			fmt.Fprintf(&b, ", validity %s -> %s", c.OldValidity, c.NewValidity)
+		}
+	}
+	return b.String()
+}
+
+// TrustedRootDiff lists the changes between two trusted roots. It renders as
+// one line per change with String, and as JSON with encoding/json.
+type TrustedRootDiff struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty reports whether the trusted roots are equivalent.
+func (d *TrustedRootDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+func (d *TrustedRootDiff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	lines := make([]string, len(d.Changes))
+	for i, c := range d.Changes {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffTrustedRoots compares two trusted roots, e.g. the current one and a new
+// one delivered by TUF, and reports every added, removed and modified trust
+// anchor. Changes are ordered by category, then by ID.
+func DiffTrustedRoots(oldRoot, newRoot *TrustedRoot) *TrustedRootDiff {
+	d := &TrustedRootDiff{Changes: []Change{}}
+	d.diffLogs(CategoryTransparencyLog, oldRoot.transparencyLogs, newRoot.transparencyLogs)
+	d.diffLogs(CategoryCTLog, oldRoot.ctLogs, newRoot.ctLogs)
+	d.diffAuthorities(CategoryCertificateAuthority, oldRoot.certificateAuthorities, newRoot.certificateAuthorities)
+	d.diffAuthorities(CategoryTimestampAuthority, oldRoot.timestampAuthorities, newRoot.timestampAuthorities)
+	return d
+}
+
+func (d *TrustedRootDiff) diffLogs(category string, oldLogs, newLogs map[string]*TransparencyLog) {
+	var changes []Change
+	for id, o := range oldLogs {
+		n, ok := newLogs[id]
+		if !ok {
+			changes = append(changes, Change{
+				Kind:        ChangeRemoved,
+				Category:    category,
+				ID:          id,
+				URL:         o.BaseURL,
+				OldValidity: newValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd),
+			})
+			continue
+		}
+		var fields []string
+		if o.BaseURL != n.BaseURL {
+			fields = append(fields, "base_url")
+		}
+		if o.HashFunc != n.HashFunc {
+			fields = append(fields, "hash_algorithm")
+		}
+		if cryptoutils.EqualKeys(o.PublicKey, n.PublicKey) != nil || o.SignatureHashFunc != n.SignatureHashFunc {
+			fields = append(fields, "public_key")
+		}
+		if !sameValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd, n.ValidityPeriodStart, n.ValidityPeriodEnd) {
+			fields = append(fields, "valid_for")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{
+				Kind:        ChangeModified,
+				Category:    category,
+				ID:          id,
+				URL:         n.BaseURL,
+				Fields:      fields,
+				OldValidity: newValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd),
+				NewValidity: newValidityPeriod(n.ValidityPeriodStart, n.ValidityPeriodEnd),
+			})
+		}
+	}
+	for id, n := range newLogs {
+		if _, ok := oldLogs[id]; !ok {
+			changes = append(changes, Change{
+				Kind:        ChangeAdded,
+				Category:    category,
+				ID:          id,
+				URL:         n.BaseURL,
+				NewValidity: newValidityPeriod(n.ValidityPeriodStart, n.ValidityPeriodEnd),
+			})
+		}
+	}
+	d.append(changes)
+}
+
+func (d *TrustedRootDiff) diffAuthorities(category string, oldCAs, newCAs []*CertificateAuthority) {
+	oldByID := authoritiesByID(oldCAs)
+	newByID := authoritiesByID(newCAs)
+	var changes []Change
+	for id, o := range oldByID {
+		n, ok := newByID[id]
+		if !ok {
+			changes = append(changes, Change{
+				Kind:        ChangeRemoved,
+				Category:    category,
+				ID:          id,
+				URL:         o.URI,
+				OldValidity: newValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd),
+			})
+			continue
+		}
+		var fields []string
+		if o.URI != n.URI {
+			fields = append(fields, "uri")
+		}
+		if !sameValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd, n.ValidityPeriodStart, n.ValidityPeriodEnd) {
+			fields = append(fields, "valid_for")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{
+				Kind:        ChangeModified,
+				Category:    category,
+				ID:          id,
+				URL:         n.URI,
+				Fields:      fields,
+				OldValidity: newValidityPeriod(o.ValidityPeriodStart, o.ValidityPeriodEnd),
+				NewValidity: newValidityPeriod(n.ValidityPeriodStart, n.ValidityPeriodEnd),
+			})
+		}
+	}
+	for id, n := range newByID {
+		if _, ok := oldByID[id]; !ok {
+			changes = append(changes, Change{
+				Kind:        ChangeAdded,
+				Category:    category,
+				ID:          id,
+				URL:         n.URI,
+				NewValidity: newValidityPeriod(n.ValidityPeriodStart, n.ValidityPeriodEnd),
+			})
+		}
+	}
+	d.append(changes)
+}
+
+func (d *TrustedRootDiff) append(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].ID != changes[j].ID {
+			return changes[i].ID < changes[j].ID
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	d.Changes = append(d.Changes, changes...)
+}
+
+func authoritiesByID(cas []*CertificateAuthority) map[string]*CertificateAuthority {
+	byID := make(map[string]*CertificateAuthority, len(cas))
+	for _, ca := range cas {
+		byID[authorityID(ca)] = ca
+	}
+	return byID
+}
@@ -0,0 +1,94 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiffTrustedRoots(t *testing.T) {
+	oldPB := newTestTrustedRoot(t)
+	newPB := proto.Clone(oldPB).(*prototrustroot.TrustedRoot)
+	// Rotate the CT log, retire the first CA and add a TSA.
+	rotated := newTestLog(t, "https://ctfe.example.com", testEnd, time.Time{})
+	newPB.Ctlogs[0].PublicKey.ValidFor = testTimeRange(testStart, testEnd)
+	newPB.Ctlogs = append(newPB.Ctlogs, rotated)
+	newPB.CertificateAuthorities = newPB.CertificateAuthorities[1:]
+	newPB.TimestampAuthorities = []*prototrustroot.CertificateAuthority{
+		newTestCA(t, "https://tsa.example.com", testStart, time.Time{}),
+	}
+
+	oldRoot, err := NewTrustedRootFromProtobuf(oldPB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRoot, err := NewTrustedRootFromProtobuf(newPB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := DiffTrustedRoots(oldRoot, oldRoot); !d.Empty() {
+		t.Errorf("expected no changes between identical roots, got %s", d)
+	}
+
+	d := DiffTrustedRoots(oldRoot, newRoot)
+	want := []struct {
+		kind     ChangeKind
+		category string
+		id       string
+	}{
+		{ChangeModified, CategoryCTLog, logIDOf(oldPB.Ctlogs[0])},
+		{ChangeAdded, CategoryCTLog, logIDOf(rotated)},
+		{ChangeRemoved, CategoryCertificateAuthority, authorityID(oldRoot.CertificateAuthorities()[0])},
+		{ChangeAdded, CategoryTimestampAuthority, authorityID(newRoot.TimestampAuthorities()[0])},
+	}
+	if len(d.Changes) != len(want) {
+		t.Fatalf("expected %d changes, got %s", len(want), d)
+	}
+	for _, w := range want {
+		found := false
+		for _, c := range d.Changes {
+			if c.Kind == w.kind && c.Category == w.category && c.ID == w.id {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing %s %s %s in %s", w.category, w.kind, w.id, d)
+		}
+	}
+
+	if !strings.Contains(d.String(), "valid_for changed, validity 2022-01-01T00:00:00Z to unbounded -> 2022-01-01T00:00:00Z to 2023-01-01T00:00:00Z") {
+		t.Errorf("unexpected rendering of the CT log rotation:\n%s", d)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TrustedRootDiff
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Changes) != len(d.Changes) || decoded.Changes[0].Kind != d.Changes[0].Kind {
+		t.Errorf("JSON rendering did not round trip: %s", b)
+	}
+}
@@ -0,0 +1,111 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NotFoundError is returned when the trusted root contains no trust anchor
+// matching a lookup.
+type NotFoundError struct {
+	// Kind is the kind of trust anchor, e.g. "transparency log".
+	Kind string
+	// ID identifies the anchor that was looked up, if any.
+	ID string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("no trusted %s found", e.Kind)
+	}
+	return fmt.Sprintf("trusted %s %s not found", e.Kind, e.ID)
+}
+
+// ExpiredError is returned when the trusted root contains a matching trust
+// anchor, but the anchor was not in use at the requested time, either because
+// its validity period had ended or because it had not started yet.
+type ExpiredError struct {
+	// Kind is the kind of trust anchor, e.g. "transparency log".
+	Kind string
+	// ID identifies the anchor that was looked up, if any.
+	ID string
+	// Time is the time the anchor was looked up at.
+	Time time.Time
+	// ValidityPeriodStart and ValidityPeriodEnd are the validity period of
+	// the anchor, when a single anchor was looked up.
+	ValidityPeriodStart time.Time
+	ValidityPeriodEnd   time.Time
+}
+
+func (e *ExpiredError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("no trusted %s valid at %s", e.Kind, e.Time.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("trusted %s %s not valid at %s (valid from %s to %s)",
+		e.Kind, e.ID, e.Time.UTC().Format(time.RFC3339),
+		formatValidityTime(e.ValidityPeriodStart), formatValidityTime(e.ValidityPeriodEnd))
+}
+
+func formatValidityTime(t time.Time) string {
+	if t.IsZero() {
+		return "unbounded"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ConflictError is returned when trusted roots from different sources cannot
+// be merged because they disagree about the same trust anchor.
+type ConflictError struct {
+	// Kind is the kind of trust anchor, e.g. "transparency log".
+	Kind string
+	// ID identifies the conflicting anchor.
+	ID string
+	// Sources names the providers that disagree.
+	Sources []string
+	// Reason describes the disagreement.
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting trusted %s %s from %s: %s",
+		e.Kind, e.ID, strings.Join(e.Sources, ", "), e.Reason)
+}
+
+// StaleError is returned when a cached trusted root is older than the
+// allowed maximum staleness because refreshing it kept failing.
+type StaleError struct {
+	// Age is the time since the trusted root was last fetched.
+	Age time.Duration
+	// MaxStaleness is the maximum allowed age.
+	MaxStaleness time.Duration
+	// Err is the last refresh error, if any.
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	msg := fmt.Sprintf("trusted root is stale: fetched %s ago, max staleness %s", e.Age, e.MaxStaleness)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
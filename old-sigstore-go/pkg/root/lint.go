@@ -0,0 +1,311 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Severity ranks the impact of a trusted root lint finding.
+type Severity int
+
+const (
+	// SeverityInfo findings are worth knowing but do not affect verification.
+	SeverityInfo Severity = iota
+	// SeverityWarning findings are likely mistakes that may weaken
+	// verification.
+	SeverityWarning
+	// SeverityError findings make a trust anchor unusable or unsafe.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Finding is a single problem found in a trusted root.
+type Finding struct {
+	Severity Severity
+	// Path locates the offending entry, e.g. "tlogs[1].public_key".
+	Path string
+	// Message describes the problem.
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Path, f.Message)
+}
+
+// HasErrors reports whether any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// LintTrustedRootJSON parses a trusted_root.json document and lints it. An
+// error is only returned when the document cannot be parsed at all.
+func LintTrustedRootJSON(rootJSON []byte) ([]Finding, error) {
+	pb := &prototrustroot.TrustedRoot{}
+	if err := protojson.Unmarshal(rootJSON, pb); err != nil {
+		return nil, fmt.Errorf("unmarshaling trusted root: %w", err)
+	}
+	return LintTrustedRoot(pb), nil
+}
+
+// LintTrustedRoot checks a trusted root for problems that would silently
+// weaken verification: inverted or overlapping validity periods, log IDs
+// that do not match their key, unparseable keys and certificates,
+// certificate chains that do not chain, and unsupported key algorithms.
+// Unlike NewTrustedRootFromProtobuf, it reports every problem it finds rather
+// than stopping at the first one.
+func LintTrustedRoot(pb *prototrustroot.TrustedRoot) []Finding {
+	l := &linter{}
+	if pb.GetMediaType() != TrustedRootMediaType01 {
+		l.errorf("media_type", "unsupported media type %q", pb.GetMediaType())
+	}
+	if len(pb.GetTlogs()) == 0 {
+		l.warnf("tlogs", "no transparency logs")
+	}
+	if len(pb.GetCertificateAuthorities()) == 0 {
+		l.warnf("certificate_authorities", "no certificate authorities")
+	}
+	l.lintLogs("tlogs", pb.GetTlogs())
+	l.lintLogs("ctlogs", pb.GetCtlogs())
+	l.lintAuthorities("certificate_authorities", pb.GetCertificateAuthorities())
+	l.lintAuthorities("timestamp_authorities", pb.GetTimestampAuthorities())
+	return l.findings
+}
+
+type linter struct {
+	findings []Finding
+}
+
+func (l *linter) add(s Severity, path, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{Severity: s, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) errorf(path, format string, args ...interface{}) {
+	l.add(SeverityError, path, format, args...)
+}
+
+func (l *linter) warnf(path, format string, args ...interface{}) {
+	l.add(SeverityWarning, path, format, args...)
+}
+
+// window is the validity period of an entry, grouped by the URL of the
+// service it belongs to when checking for overlaps.
+type window struct {
+	path       string
+	start, end time.Time
+}
+
+func (l *linter) lintLogs(field string, logs []*prototrustroot.TransparencyLogInstance) {
+	seen := make(map[string]string)
+	windows := make(map[string][]window)
+	for i, log := range logs {
+		path := fmt.Sprintf("%s[%d]", field, i)
+		if log.GetHashAlgorithm() != protocommon.HashAlgorithm_SHA2_256 {
+			l.errorf(path+".hash_algorithm", "unsupported hash algorithm %s", log.GetHashAlgorithm())
+		}
+		if log.GetLogId() == nil || len(log.GetLogId().GetKeyId()) == 0 {
+			l.errorf(path+".log_id", "missing log ID")
+		}
+		id := hex.EncodeToString(log.GetLogId().GetKeyId())
+		if other, ok := seen[id]; ok && id != "" {
+			l.errorf(path+".log_id", "duplicate log ID %s, also used by %s", id, other)
+		}
+		seen[id] = path
+
+		keyPath := path + ".public_key"
+		if log.GetPublicKey() == nil {
+			l.errorf(keyPath, "missing public key")
+			continue
+		}
+		if w, ok := l.lintTimeRange(keyPath+".valid_for", log.GetPublicKey().GetValidFor()); ok {
+			windows[log.GetBaseUrl()] = append(windows[log.GetBaseUrl()], w)
+		}
+		pub, _, err := parsePublicKey(log.GetPublicKey())
+		if err != nil {
+			l.errorf(keyPath, "%v", err)
+			continue
+		}
+		l.lintKeyAlgorithm(keyPath, pub, log.GetPublicKey().GetKeyDetails())
+		computed, err := tlog.ComputeLogID(pub)
+		if err != nil {
+			l.errorf(keyPath, "computing log ID: %v", err)
+			continue
+		}
+		if id != "" && computed != id {
+			l.errorf(path+".log_id", "log ID %s does not match SHA-256 of the public key %s", id, computed)
+		}
+	}
+	l.lintOverlaps(windows)
+}
+
+func (l *linter) lintAuthorities(field string, cas []*prototrustroot.CertificateAuthority) {
+	windows := make(map[string][]window)
+	for i, ca := range cas {
+		path := fmt.Sprintf("%s[%d]", field, i)
+		w, ok := l.lintTimeRange(path+".valid_for", ca.GetValidFor())
+		if ok {
+			windows[ca.GetUri()] = append(windows[ca.GetUri()], w)
+		}
+		pbCerts := ca.GetCertChain().GetCertificates()
+		if len(pbCerts) == 0 {
+			l.errorf(path+".cert_chain", "empty certificate chain")
+			continue
+		}
+		certs := make([]*x509.Certificate, len(pbCerts))
+		for j, pbCert := range pbCerts {
+			certPath := fmt.Sprintf("%s.cert_chain.certificates[%d]", path, j)
+			cert, err := x509.ParseCertificate(pbCert.GetRawBytes())
+			if err != nil {
+				l.errorf(certPath, "parsing certificate: %v", err)
+				continue
+			}
+			certs[j] = cert
+			if err := cryptoutils.ValidatePubKey(cert.PublicKey); err != nil {
+				l.errorf(certPath, "unsupported key algorithm: %v", err)
+			}
+			if ok && (w.start.Before(cert.NotBefore) || (!w.end.IsZero() && w.end.After(cert.NotAfter))) {
+				l.warnf(certPath, "validity period of the chain exceeds the validity of certificate %q",
+					cert.Subject.CommonName)
+			}
+		}
+		l.lintChain(path+".cert_chain", certs)
+	}
+	l.lintOverlaps(windows)
+}
+
+// lintChain checks that each certificate is signed by the next one and that
+// the last certificate is a self-signed root. Unparseable certificates are
+// nil and already reported.
+func (l *linter) lintChain(path string, certs []*x509.Certificate) {
+	for j := 0; j < len(certs)-1; j++ {
+		child, parent := certs[j], certs[j+1]
+		if child == nil || parent == nil {
+			continue
+		}
+		if err := child.CheckSignatureFrom(parent); err != nil {
+			l.errorf(fmt.Sprintf("%s.certificates[%d]", path, j),
+				"certificate %q is not signed by %q: %v", child.Subject.CommonName, parent.Subject.CommonName, err)
+		}
+	}
+	root := certs[len(certs)-1]
+	if root == nil {
+		return
+	}
+	if err := root.CheckSignatureFrom(root); err != nil {
+		l.errorf(fmt.Sprintf("%s.certificates[%d]", path, len(certs)-1),
+			"root certificate %q is not self-signed: %v", root.Subject.CommonName, err)
+	}
+}
+
+// lintKeyAlgorithm checks the key matches its declared details and is
+// strong enough to be used.
+func (l *linter) lintKeyAlgorithm(path string, pub crypto.PublicKey, details protocommon.PublicKeyDetails) {
+	var matches bool
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		switch details {
+		case protocommon.PublicKeyDetails_PKCS1_RSA_PKCS1V5, protocommon.PublicKeyDetails_PKCS1_RSA_PSS,
+			protocommon.PublicKeyDetails_PKIX_RSA_PKCS1V5, protocommon.PublicKeyDetails_PKIX_RSA_PSS:
+			matches = true
+		}
+	case *ecdsa.PublicKey:
+		matches = k.Curve == elliptic.P256() &&
+			(details == protocommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256 ||
+				details == protocommon.PublicKeyDetails_PKIX_ECDSA_P256_HMAC_SHA_256)
+	case ed25519.PublicKey:
+		matches = details == protocommon.PublicKeyDetails_PKIX_ED25519
+	}
+	if !matches {
+		l.errorf(path, "unsupported key algorithm: %T does not match key details %s", pub, details)
+		return
+	}
+	if err := cryptoutils.ValidatePubKey(pub); err != nil {
+		l.errorf(path, "unsupported key algorithm: %v", err)
+	}
+}
+
+// lintTimeRange reports a missing or inverted validity period, and returns
+// the period if it can be used to check for overlaps.
+func (l *linter) lintTimeRange(path string, tr *protocommon.TimeRange) (window, bool) {
+	if tr.GetStart() == nil {
+		l.warnf(path, "missing start of validity period")
+		return window{}, false
+	}
+	start, end := parseTimeRange(tr)
+	if !end.IsZero() && end.Before(start) {
+		l.errorf(path, "validity period ends (%s) before it starts (%s)",
+			end.UTC().Format(time.RFC3339), start.UTC().Format(time.RFC3339))
+		return window{}, false
+	}
+	return window{path: path, start: start, end: end}, true
+}
+
+// lintOverlaps warns about entries for the same service whose validity
+// periods overlap, which usually means a rotated key or certificate was not
+// given an end.
+func (l *linter) lintOverlaps(windowsByURL map[string][]window) {
+	urls := make([]string, 0, len(windowsByURL))
+	for u := range windowsByURL {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		windows := windowsByURL[u]
+		sort.SliceStable(windows, func(i, j int) bool { return windows[i].start.Before(windows[j].start) })
+		// latest is the window ending last among those starting earlier,
+		// which is the one a window overlaps if it overlaps any.
+		latest := windows[0]
+		for _, cur := range windows[1:] {
+			if latest.end.IsZero() || cur.start.Before(latest.end) {
+				l.warnf(cur.path, "validity period overlaps with %s for %q", latest.path, u)
+			}
+			if !latest.end.IsZero() && (cur.end.IsZero() || cur.end.After(latest.end)) {
+				latest = cur
+			}
+		}
+	}
+}
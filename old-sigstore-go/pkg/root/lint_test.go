@@ -0,0 +1,217 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestLintTrustedRoot(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mutate   func(pb *prototrustroot.TrustedRoot)
+		wantPath string
+		wantSev  Severity
+	}{
+		{
+			name:   "valid",
+			mutate: func(*prototrustroot.TrustedRoot) {},
+		},
+		{
+			name: "inverted validity period",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.Tlogs[0].PublicKey.ValidFor = testTimeRange(testEnd, testStart)
+			},
+			wantPath: "tlogs[0].public_key.valid_for",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "overlapping validity periods",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.CertificateAuthorities[0].ValidFor = testTimeRange(testStart, time.Time{})
+			},
+			wantPath: "certificate_authorities[1].valid_for",
+			wantSev:  SeverityWarning,
+		},
+		{
+			name: "log ID does not match key",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.Ctlogs[0].LogId = &protocommon.LogId{KeyId: []byte("foo")}
+			},
+			wantPath: "ctlogs[0].log_id",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "unparseable key",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.Tlogs[1].PublicKey.RawBytes = []byte("foo")
+			},
+			wantPath: "tlogs[1].public_key",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "key details do not match key",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.Tlogs[1].PublicKey.KeyDetails = protocommon.PublicKeyDetails_PKIX_ED25519
+			},
+			wantPath: "tlogs[1].public_key",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "unspecified key details",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.Tlogs[1].PublicKey.KeyDetails = protocommon.PublicKeyDetails_PUBLIC_KEY_DETAILS_UNSPECIFIED
+			},
+			wantPath: "tlogs[1].public_key",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "unparseable certificate",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				pb.CertificateAuthorities[0].CertChain.Certificates[0].RawBytes = []byte("foo")
+			},
+			wantPath: "certificate_authorities[0].cert_chain.certificates[0]",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "chain does not chain",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				other := newTestCA(t, "https://fulcio.example.com", testStart, testEnd)
+				pb.CertificateAuthorities[0].CertChain.Certificates[0] = other.CertChain.Certificates[0]
+			},
+			wantPath: "certificate_authorities[0].cert_chain.certificates[0]",
+			wantSev:  SeverityError,
+		},
+		{
+			name: "root is not self-signed",
+			mutate: func(pb *prototrustroot.TrustedRoot) {
+				certs := pb.CertificateAuthorities[0].CertChain.Certificates
+				pb.CertificateAuthorities[0].CertChain.Certificates = certs[:1]
+			},
+			wantPath: "certificate_authorities[0].cert_chain.certificates[0]",
+			wantSev:  SeverityError,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pb := newTestTrustedRoot(t)
+			tc.mutate(pb)
+			findings := LintTrustedRoot(pb)
+			if tc.wantPath == "" {
+				if len(findings) != 0 {
+					t.Fatalf("LintTrustedRoot unexpectedly returned findings: %v", findings)
+				}
+				return
+			}
+			for _, f := range findings {
+				if f.Path == tc.wantPath && f.Severity == tc.wantSev {
+					return
+				}
+			}
+			t.Errorf("LintTrustedRoot returned %v, expected %s finding for %s", findings, tc.wantSev, tc.wantPath)
+		})
+	}
+}
+
+func TestLintTrustedRootJSON(t *testing.T) {
+	pb := newTestTrustedRoot(t)
+	pb.MediaType = ""
+	rootJSON, err := protojson.Marshal(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := LintTrustedRootJSON(rootJSON)
+	if err != nil {
+		t.Fatalf("LintTrustedRootJSON unexpectedly returned an error: %v", err)
+	}
+	if !HasErrors(findings) {
+		t.Errorf("LintTrustedRootJSON returned %v, expected a media type error", findings)
+	}
+	if _, err := LintTrustedRootJSON([]byte("[]")); err == nil {
+		t.Error("LintTrustedRootJSON returned, expected error for malformed JSON")
+	}
+}
+
+func TestLintOverlaps(t *testing.T) {
+	at := func(days int) time.Time {
+		return testStart.AddDate(0, 0, days)
+	}
+	testCases := []struct {
+		name      string
+		windows   []window
+		wantPaths []string
+	}{
+		{
+			name: "consecutive",
+			windows: []window{
+				{path: "a", start: at(0), end: at(10)},
+				{path: "b", start: at(10), end: at(20)},
+				{path: "c", start: at(20)},
+			},
+		},
+		{
+			name: "overlapping the previous one",
+			windows: []window{
+				{path: "a", start: at(0), end: at(10)},
+				{path: "b", start: at(5)},
+			},
+			wantPaths: []string{"b"},
+		},
+		{
+			name: "overlapping an earlier, longer one",
+			windows: []window{
+				{path: "a", start: at(0), end: at(100)},
+				{path: "b", start: at(10), end: at(20)},
+				{path: "c", start: at(30), end: at(40)},
+			},
+			wantPaths: []string{"b", "c"},
+		},
+		{
+			name: "after an open-ended one",
+			windows: []window{
+				{path: "a", start: at(0)},
+				{path: "b", start: at(10), end: at(20)},
+				{path: "c", start: at(30), end: at(40)},
+			},
+			wantPaths: []string{"b", "c"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			l := &linter{}
+			l.lintOverlaps(map[string][]window{"https://rekor.example.com": tc.windows})
+			var paths []string
+			for _, f := range l.findings {
+				if f.Severity != SeverityWarning || !strings.Contains(f.Message, `"https://rekor.example.com"`) {
+					t.Errorf("unexpected finding %v", f)
+				}
+				paths = append(paths, f.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.wantPaths) {
+				t.Errorf("expected overlap warnings for %v, got %v", tc.wantPaths, paths)
+			}
+		})
+	}
+}
@@ -18,12 +18,14 @@
 //	implemented with a TUF client or other
 package root
 
+import "context"
+
 // TrustedRootProvider is an interface that can generate a trusted
 // root, be it from a TUF client, local filesystem information, or
 // other method to retrieve the trusted root.
 type TrustedRootProvider interface {
-	// GetTrustedRoot returns a TrustedRootStore containing the
+	// GetTrustedRoot returns a TrustedRoot containing the
 	// Sigstore ecosystem information for a verification client to
-	// consume.
-	GetTrustedRoot() (interface{}, error)
+	// consume. Any network call made to retrieve it is bound to ctx.
+	GetTrustedRoot(ctx context.Context) (*TrustedRoot, error)
 }
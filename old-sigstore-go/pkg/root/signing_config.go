@@ -0,0 +1,170 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SigningConfigMediaType02 is the media type of a version 0.2 signing
+// configuration.
+const SigningConfigMediaType02 = "application/vnd.dev.sigstore.signingconfig.v0.2+json"
+
+// SigningConfig lists the service endpoints a signer uses: certificate
+// authorities (Fulcio), OIDC providers, transparency logs (Rekor) and
+// timestamping authorities. It is distributed alongside the trusted root, so
+// that signers need no hardcoded URLs.
+type SigningConfig struct {
+	MediaType string `json:"mediaType"`
+	// CertificateAuthorityURLs are the Fulcio instances.
+	CertificateAuthorityURLs []Service `json:"caUrls,omitempty"`
+	// OIDCURLs are the OIDC providers issuing identity tokens.
+	OIDCURLs []Service `json:"oidcUrls,omitempty"`
+	// TransparencyLogURLs are the Rekor instances.
+	TransparencyLogURLs []Service `json:"rekorTlogUrls,omitempty"`
+	// TransparencyLogConfig selects the Rekor instances to upload to.
+	TransparencyLogConfig ServiceConfiguration `json:"rekorTlogConfig"`
+	// TimestampAuthorityURLs are the timestamping authorities.
+	TimestampAuthorityURLs []Service `json:"tsaUrls,omitempty"`
+	// TimestampAuthorityConfig selects the timestamping authorities to
+	// request timestamps from.
+	TimestampAuthorityConfig ServiceConfiguration `json:"tsaConfig"`
+}
+
+// Service is a service endpoint.
+type Service struct {
+	URL string `json:"url"`
+	// MajorAPIVersion is the major version of the API the service serves.
+	MajorAPIVersion uint32 `json:"majorApiVersion"`
+	// ValidFor is the period during which the service may be used.
+	ValidFor ValidityPeriod `json:"validFor"`
+	// Operator identifies the organization running the service, e.g.
+	// "sigstore.dev".
+	Operator string `json:"operator,omitempty"`
+}
+
+// ValidAt reports whether the service may be used at time t.
+func (s Service) ValidAt(t time.Time) bool {
+	var end time.Time
+	if s.ValidFor.End != nil {
+		end = *s.ValidFor.End
+	}
+	return validAt(s.ValidFor.Start, end, t)
+}
+
+// ServiceSelector determines how many services are selected.
+type ServiceSelector string
+
+const (
+	// SelectorAll selects a service from every operator.
+	SelectorAll ServiceSelector = "ALL"
+	// SelectorAny selects a single service.
+	SelectorAny ServiceSelector = "ANY"
+	// SelectorExact selects services from exactly Count operators.
+	SelectorExact ServiceSelector = "EXACT"
+)
+
+// ServiceConfiguration determines which of several services are used.
+type ServiceConfiguration struct {
+	Selector ServiceSelector `json:"selector"`
+	// Count is the number of operators to select with SelectorExact.
+	Count uint32 `json:"count,omitempty"`
+}
+
+// NewSigningConfigFromJSON parses a signing configuration document.
+func NewSigningConfigFromJSON(configJSON []byte) (*SigningConfig, error) {
+	sc := &SigningConfig{}
+	if err := json.Unmarshal(configJSON, sc); err != nil {
+		return nil, fmt.Errorf("unmarshaling signing config: %w", err)
+	}
+	if sc.MediaType != SigningConfigMediaType02 {
+		return nil, fmt.Errorf("unsupported signing config media type: %q", sc.MediaType)
+	}
+	return sc, nil
+}
+
+// SelectService returns the service to use at time t among services, such as
+// the Fulcio or OIDC URLs of a SigningConfig. Only services valid at t and
+// serving one of the supported major API versions are considered; the
+// highest API version is preferred, then the first service listed.
+func SelectService(services []Service, supportedAPIVersions []uint32, t time.Time) (Service, error) {
+	candidates := selectCandidates(services, supportedAPIVersions, t)
+	if len(candidates) == 0 {
+		return Service{}, &NotFoundError{Kind: "service"}
+	}
+	return candidates[0], nil
+}
+
+// SelectServices returns the services to use at time t among services,
+// according to config, such as the Rekor or TSA URLs of a SigningConfig. At
+// most one service is selected per operator, preferring the highest API
+// version, then the first service listed. Each service without an operator
+// counts as its own operator.
+func SelectServices(services []Service, config ServiceConfiguration, supportedAPIVersions []uint32, t time.Time) ([]Service, error) {
+	candidates := selectCandidates(services, supportedAPIVersions, t)
+	var selected []Service
+	operators := make(map[string]bool)
+	for _, s := range candidates {
+		// Services without an operator are not known to share one.
+		if s.Operator != "" {
+			if operators[s.Operator] {
+				continue
+			}
+			operators[s.Operator] = true
+		}
+		selected = append(selected, s)
+	}
+	switch config.Selector {
+	case SelectorAll:
+	case SelectorAny:
+		if len(selected) > 1 {
+			selected = selected[:1]
+		}
+	case SelectorExact:
+		if uint32(len(selected)) < config.Count {
+			return nil, fmt.Errorf("%d services required, %d available", config.Count, len(selected))
+		}
+		selected = selected[:config.Count]
+	default:
+		return nil, fmt.Errorf("unsupported service selector %q", config.Selector)
+	}
+	if len(selected) == 0 {
+		return nil, &NotFoundError{Kind: "service"}
+	}
+	return selected, nil
+}
+
+// selectCandidates filters services valid at t with a supported API version,
+// ordered by descending API version, then by their order in services.
+func selectCandidates(services []Service, supportedAPIVersions []uint32, t time.Time) []Service {
+	supported := make(map[uint32]bool, len(supportedAPIVersions))
+	for _, v := range supportedAPIVersions {
+		supported[v] = true
+	}
+	var candidates []Service
+	for _, s := range services {
+		if s.ValidAt(t) && supported[s.MajorAPIVersion] {
+			candidates = append(candidates, s)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].MajorAPIVersion > candidates[j].MajorAPIVersion
+	})
+	return candidates
+}
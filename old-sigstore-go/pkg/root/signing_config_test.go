@@ -0,0 +1,234 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewSigningConfigFromJSON(t *testing.T) {
+	testCases := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			json: `{
+				"mediaType": "application/vnd.dev.sigstore.signingconfig.v0.2+json",
+				"caUrls": [{"url": "https://fulcio.sigstore.dev", "majorApiVersion": 1,
+					"validFor": {"start": "2023-01-01T00:00:00Z"}, "operator": "sigstore.dev"}],
+				"rekorTlogConfig": {"selector": "EXACT", "count": 1}
+			}`,
+		},
+		{
+			name:    "unsupported media type",
+			json:    `{"mediaType": "application/vnd.dev.sigstore.signingconfig.v0.1+json"}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			json:    `{"caUrls": {}}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			sc, err := NewSigningConfigFromJSON([]byte(tc.json))
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("NewSigningConfigFromJSON unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("NewSigningConfigFromJSON returned, expected error")
+			}
+			if len(sc.CertificateAuthorityURLs) != 1 || sc.CertificateAuthorityURLs[0].Operator != "sigstore.dev" {
+				t.Errorf("unexpected certificate authority URLs %v", sc.CertificateAuthorityURLs)
+			}
+			if sc.TransparencyLogConfig.Selector != SelectorExact || sc.TransparencyLogConfig.Count != 1 {
+				t.Errorf("unexpected transparency log config %v", sc.TransparencyLogConfig)
+			}
+		})
+	}
+}
+
+func newTestService(url, operator string, version uint32, start, end time.Time) Service {
+	s := Service{URL: url, MajorAPIVersion: version, Operator: operator}
+	s.ValidFor.Start = start
+	if !end.IsZero() {
+		s.ValidFor.End = &end
+	}
+	return s
+}
+
+func TestSelectService(t *testing.T) {
+	services := []Service{
+		newTestService("https://expired", "a", 2, testStart, testEnd),
+		newTestService("https://v1", "a", 1, testStart, time.Time{}),
+		newTestService("https://v2", "a", 2, testStart, time.Time{}),
+		newTestService("https://v2-later", "b", 2, testStart, time.Time{}),
+	}
+	at := testEnd.Add(time.Hour)
+	testCases := []struct {
+		name      string
+		versions  []uint32
+		at        time.Time
+		wantURL   string
+		wantFound bool
+	}{
+		{
+			name:      "highest version",
+			versions:  []uint32{1, 2},
+			at:        at,
+			wantURL:   "https://v2",
+			wantFound: true,
+		},
+		{
+			name:      "supported version only",
+			versions:  []uint32{1},
+			at:        at,
+			wantURL:   "https://v1",
+			wantFound: true,
+		},
+		{
+			name:      "within validity period",
+			versions:  []uint32{2},
+			at:        testStart,
+			wantURL:   "https://expired",
+			wantFound: true,
+		},
+		{
+			name:     "unsupported version",
+			versions: []uint32{3},
+			at:       at,
+		},
+		{
+			name:     "before validity period",
+			versions: []uint32{1, 2},
+			at:       testStart.Add(-time.Hour),
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := SelectService(services, tc.versions, tc.at)
+			if !tc.wantFound {
+				var notFound *NotFoundError
+				if !errors.As(err, &notFound) {
+					t.Fatalf("SelectService returned %v, expected NotFoundError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectService unexpectedly returned an error: %v", err)
+			}
+			if s.URL != tc.wantURL {
+				t.Errorf("SelectService returned %s, expected %s", s.URL, tc.wantURL)
+			}
+		})
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	services := []Service{
+		newTestService("https://a1", "a", 1, testStart, time.Time{}),
+		newTestService("https://a2", "a", 2, testStart, time.Time{}),
+		newTestService("https://b1", "b", 1, testStart, time.Time{}),
+		newTestService("https://c1", "c", 1, testStart, testEnd),
+	}
+	at := testEnd.Add(time.Hour)
+	testCases := []struct {
+		name     string
+		services []Service
+		config   ServiceConfiguration
+		wantURLs []string
+		wantErr  bool
+	}{
+		{
+			name:     "all",
+			config:   ServiceConfiguration{Selector: SelectorAll},
+			wantURLs: []string{"https://a2", "https://b1"},
+		},
+		{
+			name:     "any",
+			config:   ServiceConfiguration{Selector: SelectorAny},
+			wantURLs: []string{"https://a2"},
+		},
+		{
+			name:     "exact",
+			config:   ServiceConfiguration{Selector: SelectorExact, Count: 2},
+			wantURLs: []string{"https://a2", "https://b1"},
+		},
+		{
+			name:    "exact with too few operators",
+			config:  ServiceConfiguration{Selector: SelectorExact, Count: 3},
+			wantErr: true,
+		},
+		{
+			name: "exact without operators",
+			services: []Service{
+				newTestService("https://d1", "", 1, testStart, time.Time{}),
+				newTestService("https://e1", "", 1, testStart, time.Time{}),
+				newTestService("https://f1", "f", 1, testStart, time.Time{}),
+			},
+			config:   ServiceConfiguration{Selector: SelectorExact, Count: 3},
+			wantURLs: []string{"https://d1", "https://e1", "https://f1"},
+		},
+		{
+			name:    "unknown selector",
+			config:  ServiceConfiguration{Selector: "SOME"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if tc.services == nil {
+				tc.services = services
+			}
+			selected, err := SelectServices(tc.services, tc.config, []uint32{1, 2}, at)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("SelectServices unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("SelectServices returned, expected error")
+			}
+			var urls []string
+			for _, s := range selected {
+				urls = append(urls, s.URL)
+			}
+			if len(urls) != len(tc.wantURLs) {
+				t.Fatalf("SelectServices returned %v, expected %v", urls, tc.wantURLs)
+			}
+			for i := range urls {
+				if urls[i] != tc.wantURLs[i] {
+					t.Errorf("SelectServices returned %v, expected %v", urls, tc.wantURLs)
+				}
+			}
+		})
+	}
+}
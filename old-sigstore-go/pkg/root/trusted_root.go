@@ -0,0 +1,370 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TrustedRootMediaType01 is the media type of a version 0.1 trusted root.
+const TrustedRootMediaType01 = "application/vnd.dev.sigstore.trustedroot+json;version=0.1"
+
+// TrustedRoot contains the trust anchors a verifier uses to check signed
+// artifacts: transparency logs, certificate authorities, certificate
+// transparency logs and timestamping authorities. Every anchor carries a
+// validity period, so that verification can select the anchor that was
+// valid at signing time rather than the one that is current.
+type TrustedRoot struct {
+	transparencyLogs       map[string]*TransparencyLog
+	ctLogs                 map[string]*TransparencyLog
+	certificateAuthorities []*CertificateAuthority
+	timestampAuthorities   []*CertificateAuthority
+}
+
+// TransparencyLog is a trusted Rekor or certificate transparency log.
+type TransparencyLog struct {
+	// BaseURL is the URL at which the log can be reached.
+	BaseURL string
+	// ID is the hex-encoded log ID, the SHA-256 of the DER-encoded public key.
+	ID string
+	// HashFunc is the hash function used for the Merkle tree.
+	HashFunc crypto.Hash
+	// PublicKey verifies signatures generated by the log.
+	PublicKey crypto.PublicKey
+	// SignatureHashFunc is the hash function used when verifying signatures
+	// generated by the log.
+	SignatureHashFunc crypto.Hash
+	// ValidityPeriodStart is the time the log key started being used.
+	ValidityPeriodStart time.Time
+	// ValidityPeriodEnd is the time the log key stopped being used. The zero
+	// value means the key is still in use.
+	ValidityPeriodEnd time.Time
+	// Source names the provider the log was obtained from, when the trusted
+	// root was assembled from several providers.
+	Source string
+}
+
+// CertificateAuthority is a trusted certificate chain, either issuing
+// signing certificates (e.g. Fulcio) or timestamps.
+type CertificateAuthority struct {
+	// Root is the self-signed root certificate of the chain.
+	Root *x509.Certificate
+	// Intermediates are the certificates between the root and the leaf,
+	// ordered from the leaf towards the root.
+	Intermediates []*x509.Certificate
+	// Leaf is the end-entity certificate, only set for timestamping
+	// authorities whose chain includes the signing certificate.
+	Leaf *x509.Certificate
+	// URI is the location at which the authority can be reached.
+	URI string
+	// ValidityPeriodStart is the time the chain started being used.
+	ValidityPeriodStart time.Time
+	// ValidityPeriodEnd is the time the chain stopped being used. The zero
+	// value means the chain is still in use.
+	ValidityPeriodEnd time.Time
+	// Source names the provider the authority was obtained from, when the
+	// trusted root was assembled from several providers.
+	Source string
+}
+
+// ValidAt reports whether the log key was in use at time t. The validity
+// period is inclusive of both endpoints.
+func (l *TransparencyLog) ValidAt(t time.Time) bool {
+	return validAt(l.ValidityPeriodStart, l.ValidityPeriodEnd, t)
+}
+
+// ValidAt reports whether the chain was in use at time t. The validity period
+// is inclusive of both endpoints. Callers must still check t against the
+// validity of each certificate in the chain.
+func (ca *CertificateAuthority) ValidAt(t time.Time) bool {
+	return validAt(ca.ValidityPeriodStart, ca.ValidityPeriodEnd, t)
+}
+
+func sameValidityPeriod(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Equal(bStart) && aEnd.Equal(bEnd)
+}
+
+func validAt(start, end, t time.Time) bool {
+	if !start.IsZero() && t.Before(start) {
+		return false
+	}
+	if !end.IsZero() && t.After(end) {
+		return false
+	}
+	return true
+}
+
+// NewTrustedRootFromJSON parses a trusted_root.json document.
+func NewTrustedRootFromJSON(rootJSON []byte) (*TrustedRoot, error) {
+	pb := &prototrustroot.TrustedRoot{}
+	if err := protojson.Unmarshal(rootJSON, pb); err != nil {
+		return nil, fmt.Errorf("unmarshaling trusted root: %w", err)
+	}
+	return NewTrustedRootFromProtobuf(pb)
+}
+
+// NewTrustedRootFromProtobuf creates a TrustedRoot from its protobuf
+// representation, parsing every key and certificate it contains.
+func NewTrustedRootFromProtobuf(pb *prototrustroot.TrustedRoot) (*TrustedRoot, error) {
+	if pb.GetMediaType() != TrustedRootMediaType01 {
+		return nil, fmt.Errorf("unsupported trusted root media type: %q", pb.GetMediaType())
+	}
+	tr := &TrustedRoot{}
+	var err error
+	if tr.transparencyLogs, err = parseTransparencyLogs(pb.GetTlogs()); err != nil {
+		return nil, fmt.Errorf("parsing transparency logs: %w", err)
+	}
+	if tr.ctLogs, err = parseTransparencyLogs(pb.GetCtlogs()); err != nil {
+		return nil, fmt.Errorf("parsing CT logs: %w", err)
+	}
+	if tr.certificateAuthorities, err = parseCertificateAuthorities(pb.GetCertificateAuthorities()); err != nil {
+		return nil, fmt.Errorf("parsing certificate authorities: %w", err)
+	}
+	if tr.timestampAuthorities, err = parseCertificateAuthorities(pb.GetTimestampAuthorities()); err != nil {
+		return nil, fmt.Errorf("parsing timestamp authorities: %w", err)
+	}
+	return tr, nil
+}
+
+// TransparencyLogs returns the Rekor logs indexed by hex-encoded log ID.
+func (tr *TrustedRoot) TransparencyLogs() map[string]*TransparencyLog {
+	return tr.transparencyLogs
+}
+
+// CTLogs returns the certificate transparency logs indexed by hex-encoded
+// log ID.
+func (tr *TrustedRoot) CTLogs() map[string]*TransparencyLog {
+	return tr.ctLogs
+}
+
+// CertificateAuthorities returns all certificate authorities, regardless of
+// their validity period.
+func (tr *TrustedRoot) CertificateAuthorities() []*CertificateAuthority {
+	return tr.certificateAuthorities
+}
+
+// TimestampAuthorities returns all timestamping authorities, regardless of
+// their validity period.
+func (tr *TrustedRoot) TimestampAuthorities() []*CertificateAuthority {
+	return tr.timestampAuthorities
+}
+
+// TransparencyLogAt returns the Rekor log with the given hex-encoded log ID,
+// provided its key was in use at time t.
+func (tr *TrustedRoot) TransparencyLogAt(logID string, t time.Time) (*TransparencyLog, error) {
+	return logAt(tr.transparencyLogs, "transparency log", logID, t)
+}
+
+// CTLogAt returns the certificate transparency log with the given
+// hex-encoded log ID, provided its key was in use at time t.
+func (tr *TrustedRoot) CTLogAt(logID string, t time.Time) (*TransparencyLog, error) {
+	return logAt(tr.ctLogs, "CT log", logID, t)
+}
+
+// TlogVerifier returns a verifier for signatures generated at time t by the
+// Rekor log with the given hex-encoded log ID.
+func (tr *TrustedRoot) TlogVerifier(logID string, t time.Time) (signature.Verifier, error) {
+	l, err := tr.TransparencyLogAt(logID, t)
+	if err != nil {
+		return nil, err
+	}
+	return signature.LoadVerifier(l.PublicKey, l.SignatureHashFunc)
+}
+
+// CTLogVerifier returns a verifier for signatures generated at time t by the
+// certificate transparency log with the given hex-encoded log ID.
+func (tr *TrustedRoot) CTLogVerifier(logID string, t time.Time) (signature.Verifier, error) {
+	l, err := tr.CTLogAt(logID, t)
+	if err != nil {
+		return nil, err
+	}
+	return signature.LoadVerifier(l.PublicKey, l.SignatureHashFunc)
+}
+
+// CertificateAuthoritiesAt returns the certificate authorities that were in
+// use at time t.
+func (tr *TrustedRoot) CertificateAuthoritiesAt(t time.Time) ([]*CertificateAuthority, error) {
+	return authoritiesAt(tr.certificateAuthorities, "certificate authority", t)
+}
+
+// TimestampAuthoritiesAt returns the timestamping authorities that were in
+// use at time t.
+func (tr *TrustedRoot) TimestampAuthoritiesAt(t time.Time) ([]*CertificateAuthority, error) {
+	return authoritiesAt(tr.timestampAuthorities, "timestamp authority", t)
+}
+
+func logAt(logs map[string]*TransparencyLog, kind, logID string, t time.Time) (*TransparencyLog, error) {
+	l, ok := logs[logID]
+	if !ok {
+		return nil, &NotFoundError{Kind: kind, ID: logID}
+	}
+	if !l.ValidAt(t) {
+		return nil, &ExpiredError{
+			Kind:                kind,
+			ID:                  logID,
+			Time:                t,
+			ValidityPeriodStart: l.ValidityPeriodStart,
+			ValidityPeriodEnd:   l.ValidityPeriodEnd,
+		}
+	}
+	return l, nil
+}
+
+func authoritiesAt(cas []*CertificateAuthority, kind string, t time.Time) ([]*CertificateAuthority, error) {
+	if len(cas) == 0 {
+		return nil, &NotFoundError{Kind: kind}
+	}
+	var valid []*CertificateAuthority
+	for _, ca := range cas {
+		if ca.ValidAt(t) {
+			valid = append(valid, ca)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, &ExpiredError{Kind: kind, Time: t}
+	}
+	return valid, nil
+}
+
+func parseTransparencyLogs(pbLogs []*prototrustroot.TransparencyLogInstance) (map[string]*TransparencyLog, error) {
+	logs := make(map[string]*TransparencyLog, len(pbLogs))
+	for _, pbLog := range pbLogs {
+		if pbLog.GetLogId() == nil {
+			return nil, fmt.Errorf("log %s missing log ID", pbLog.GetBaseUrl())
+		}
+		id := hex.EncodeToString(pbLog.GetLogId().GetKeyId())
+		if _, ok := logs[id]; ok {
+			return nil, fmt.Errorf("duplicate log ID %s", id)
+		}
+		hashFunc, err := hashFunc(pbLog.GetHashAlgorithm())
+		if err != nil {
+			return nil, fmt.Errorf("log %s: %w", id, err)
+		}
+		pub, sigHashFunc, err := parsePublicKey(pbLog.GetPublicKey())
+		if err != nil {
+			return nil, fmt.Errorf("log %s: %w", id, err)
+		}
+		start, end := parseTimeRange(pbLog.GetPublicKey().GetValidFor())
+		logs[id] = &TransparencyLog{
+			BaseURL:             pbLog.GetBaseUrl(),
+			ID:                  id,
+			HashFunc:            hashFunc,
+			PublicKey:           pub,
+			SignatureHashFunc:   sigHashFunc,
+			ValidityPeriodStart: start,
+			ValidityPeriodEnd:   end,
+		}
+	}
+	return logs, nil
+}
+
+func parseCertificateAuthorities(pbCAs []*prototrustroot.CertificateAuthority) ([]*CertificateAuthority, error) {
+	cas := make([]*CertificateAuthority, 0, len(pbCAs))
+	for i, pbCA := range pbCAs {
+		ca, err := parseCertificateAuthority(pbCA)
+		if err != nil {
+			return nil, fmt.Errorf("authority %d (%s): %w", i, pbCA.GetUri(), err)
+		}
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+func parseCertificateAuthority(pbCA *prototrustroot.CertificateAuthority) (*CertificateAuthority, error) {
+	pbCerts := pbCA.GetCertChain().GetCertificates()
+	if len(pbCerts) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+	certs := make([]*x509.Certificate, 0, len(pbCerts))
+	for _, pbCert := range pbCerts {
+		cert, err := x509.ParseCertificate(pbCert.GetRawBytes())
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	start, end := parseTimeRange(pbCA.GetValidFor())
+	ca := &CertificateAuthority{
+		URI:                 pbCA.GetUri(),
+		ValidityPeriodStart: start,
+		ValidityPeriodEnd:   end,
+	}
+	// Chains are ordered from the leaf towards the self-signed root.
+	ca.Root = certs[len(certs)-1]
+	certs = certs[:len(certs)-1]
+	if len(certs) > 0 && !certs[0].IsCA {
+		ca.Leaf = certs[0]
+		certs = certs[1:]
+	}
+	ca.Intermediates = certs
+	return ca, nil
+}
+
+// parsePublicKey parses a public key and returns the hash function used for
+// signatures made with it.
+func parsePublicKey(pbKey *protocommon.PublicKey) (crypto.PublicKey, crypto.Hash, error) {
+	if pbKey == nil || pbKey.GetRawBytes() == nil {
+		return nil, 0, errors.New("missing public key")
+	}
+	var pub crypto.PublicKey
+	var err error
+	sigHashFunc := crypto.SHA256
+	switch pbKey.GetKeyDetails() {
+	case protocommon.PublicKeyDetails_PKCS1_RSA_PKCS1V5, protocommon.PublicKeyDetails_PKCS1_RSA_PSS:
+		pub, err = x509.ParsePKCS1PublicKey(pbKey.GetRawBytes())
+	case protocommon.PublicKeyDetails_PKIX_RSA_PKCS1V5, protocommon.PublicKeyDetails_PKIX_RSA_PSS,
+		protocommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256, protocommon.PublicKeyDetails_PKIX_ECDSA_P256_HMAC_SHA_256:
+		pub, err = x509.ParsePKIXPublicKey(pbKey.GetRawBytes())
+	case protocommon.PublicKeyDetails_PKIX_ED25519:
+		pub, err = x509.ParsePKIXPublicKey(pbKey.GetRawBytes())
+		// Ed25519 signs the message directly.
+		sigHashFunc = crypto.Hash(0)
+	default:
+		return nil, 0, fmt.Errorf("unsupported public key type %s", pbKey.GetKeyDetails())
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing public key: %w", err)
+	}
+	return pub, sigHashFunc, nil
+}
+
+func hashFunc(alg protocommon.HashAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case protocommon.HashAlgorithm_SHA2_256:
+		return crypto.SHA256, nil
+	}
+	return 0, fmt.Errorf("unsupported hash algorithm %s", alg)
+}
+
+func parseTimeRange(tr *protocommon.TimeRange) (start, end time.Time) {
+	if tr.GetStart() != nil {
+		start = tr.GetStart().AsTime()
+	}
+	if tr.GetEnd() != nil {
+		end = tr.GetEnd().AsTime()
+	}
+	return start, end
+}
@@ -0,0 +1,283 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	testStart = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	testEnd   = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func testTimeRange(start, end time.Time) *protocommon.TimeRange {
+	tr := &protocommon.TimeRange{Start: timestamppb.New(start)}
+	if !end.IsZero() {
+		tr.End = timestamppb.New(end)
+	}
+	return tr
+}
+
+// newTestLog generates a transparency log instance with a fresh ECDSA key.
+func newTestLog(t *testing.T, baseURL string, start, end time.Time) *prototrustroot.TransparencyLogInstance {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sha256.Sum256(der)
+	return &prototrustroot.TransparencyLogInstance{
+		BaseUrl:       baseURL,
+		HashAlgorithm: protocommon.HashAlgorithm_SHA2_256,
+		PublicKey: &protocommon.PublicKey{
+			RawBytes:   der,
+			KeyDetails: protocommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256,
+			ValidFor:   testTimeRange(start, end),
+		},
+		LogId: &protocommon.LogId{KeyId: id[:]},
+	}
+}
+
+// newTestCA generates a certificate authority with a self-signed root and a
+// single intermediate.
+func newTestCA(t *testing.T, uri string, start, end time.Time) *prototrustroot.CertificateAuthority {
+	t.Helper()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"sigstore.dev"}},
+		NotBefore:             start,
+		NotAfter:              start.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "intermediate", Organization: []string{"sigstore.dev"}},
+		NotBefore:             start,
+		NotAfter:              start.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	interDER, err := x509.CreateCertificate(rand.Reader, interTmpl, rootTmpl, interKey.Public(), rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &prototrustroot.CertificateAuthority{
+		Subject: &protocommon.DistinguishedName{Organization: "sigstore.dev", CommonName: "root"},
+		Uri:     uri,
+		CertChain: &protocommon.X509CertificateChain{
+			Certificates: []*protocommon.X509Certificate{{RawBytes: interDER}, {RawBytes: rootDER}},
+		},
+		ValidFor: testTimeRange(start, end),
+	}
+}
+
+// newTestTrustedRoot generates a trusted root with a rotated Rekor log and
+// Fulcio CA, where the first instance expired at testEnd.
+func newTestTrustedRoot(t *testing.T) *prototrustroot.TrustedRoot {
+	t.Helper()
+	return &prototrustroot.TrustedRoot{
+		MediaType: TrustedRootMediaType01,
+		Tlogs: []*prototrustroot.TransparencyLogInstance{
+			newTestLog(t, "https://rekor.example.com", testStart, testEnd),
+			newTestLog(t, "https://rekor.example.com", testEnd, time.Time{}),
+		},
+		CertificateAuthorities: []*prototrustroot.CertificateAuthority{
+			newTestCA(t, "https://fulcio.example.com", testStart, testEnd),
+			newTestCA(t, "https://fulcio.example.com", testEnd, time.Time{}),
+		},
+		Ctlogs: []*prototrustroot.TransparencyLogInstance{
+			newTestLog(t, "https://ctfe.example.com", testStart, time.Time{}),
+		},
+	}
+}
+
+func logIDOf(l *prototrustroot.TransparencyLogInstance) string {
+	return hex.EncodeToString(l.GetLogId().GetKeyId())
+}
+
+func TestNewTrustedRootFromJSON(t *testing.T) {
+	pb := newTestTrustedRoot(t)
+	rootJSON, err := protojson.Marshal(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewTrustedRootFromJSON(rootJSON)
+	if err != nil {
+		t.Fatalf("NewTrustedRootFromJSON unexpectedly returned an error: %v", err)
+	}
+	if len(tr.TransparencyLogs()) != 2 {
+		t.Errorf("expected 2 transparency logs, got %d", len(tr.TransparencyLogs()))
+	}
+	if len(tr.CTLogs()) != 1 {
+		t.Errorf("expected 1 CT log, got %d", len(tr.CTLogs()))
+	}
+	if len(tr.CertificateAuthorities()) != 2 {
+		t.Fatalf("expected 2 certificate authorities, got %d", len(tr.CertificateAuthorities()))
+	}
+	ca := tr.CertificateAuthorities()[0]
+	if ca.Root.Subject.CommonName != "root" || len(ca.Intermediates) != 1 || ca.Leaf != nil {
+		t.Errorf("unexpected certificate chain: root %v, %d intermediates, leaf %v",
+			ca.Root.Subject, len(ca.Intermediates), ca.Leaf)
+	}
+
+	if _, err := NewTrustedRootFromJSON([]byte("{")); err == nil {
+		t.Error("NewTrustedRootFromJSON returned, expected error for malformed JSON")
+	}
+	pb.MediaType = "application/json"
+	if _, err := NewTrustedRootFromProtobuf(pb); err == nil {
+		t.Error("NewTrustedRootFromProtobuf returned, expected error for unknown media type")
+	}
+}
+
+func TestTlogVerifier(t *testing.T) {
+	pb := newTestTrustedRoot(t)
+	tr, err := NewTrustedRootFromProtobuf(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := logIDOf(pb.Tlogs[0])
+	newID := logIDOf(pb.Tlogs[1])
+
+	testCases := []struct {
+		name        string
+		logID       string
+		at          time.Time
+		wantExpired bool
+		wantMissing bool
+	}{
+		{
+			name:  "old key during its validity",
+			logID: oldID,
+			at:    testStart.AddDate(0, 6, 0),
+		},
+		{
+			name:  "old key at end of validity",
+			logID: oldID,
+			at:    testEnd,
+		},
+		{
+			name:        "old key after rotation",
+			logID:       oldID,
+			at:          testEnd.AddDate(0, 1, 0),
+			wantExpired: true,
+		},
+		{
+			name:        "new key before rotation",
+			logID:       newID,
+			at:          testStart.AddDate(0, 6, 0),
+			wantExpired: true,
+		},
+		{
+			name:  "new key without end",
+			logID: newID,
+			at:    testEnd.AddDate(5, 0, 0),
+		},
+		{
+			name:        "unknown log",
+			logID:       "deadbeef",
+			at:          testStart,
+			wantMissing: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			v, err := tr.TlogVerifier(tc.logID, tc.at)
+			var expiredErr *ExpiredError
+			var notFoundErr *NotFoundError
+			switch {
+			case tc.wantExpired:
+				if !errors.As(err, &expiredErr) {
+					t.Fatalf("TlogVerifier returned %v, expected ExpiredError", err)
+				}
+			case tc.wantMissing:
+				if !errors.As(err, &notFoundErr) {
+					t.Fatalf("TlogVerifier returned %v, expected NotFoundError", err)
+				}
+			case err != nil:
+				t.Fatalf("TlogVerifier unexpectedly returned an error: %v", err)
+			case v == nil:
+				t.Fatal("TlogVerifier returned a nil verifier")
+			}
+		})
+	}
+}
+
+func TestCertificateAuthoritiesAt(t *testing.T) {
+	tr, err := NewTrustedRootFromProtobuf(newTestTrustedRoot(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas, err := tr.CertificateAuthoritiesAt(testStart.AddDate(0, 6, 0))
+	if err != nil {
+		t.Fatalf("CertificateAuthoritiesAt unexpectedly returned an error: %v", err)
+	}
+	if len(cas) != 1 || cas[0] != tr.CertificateAuthorities()[0] {
+		t.Errorf("expected only the first CA, got %v", cas)
+	}
+	cas, err = tr.CertificateAuthoritiesAt(testEnd)
+	if err != nil {
+		t.Fatalf("CertificateAuthoritiesAt unexpectedly returned an error: %v", err)
+	}
+	if len(cas) != 2 {
+		t.Errorf("expected both CAs at the rotation boundary, got %d", len(cas))
+	}
+
+	var expiredErr *ExpiredError
+	if _, err := tr.CertificateAuthoritiesAt(testStart.AddDate(-1, 0, 0)); !errors.As(err, &expiredErr) {
+		t.Errorf("CertificateAuthoritiesAt returned %v, expected ExpiredError", err)
+	}
+	var notFoundErr *NotFoundError
+	if _, err := tr.TimestampAuthoritiesAt(testStart); !errors.As(err, &notFoundErr) {
+		t.Errorf("TimestampAuthoritiesAt returned %v, expected NotFoundError", err)
+	}
+}
@@ -0,0 +1,284 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+const (
+	// archiveMetadataDir and archiveTargetsDir are the directories of the
+	// metadata and targets in an archive.
+	archiveMetadataDir = "metadata"
+	archiveTargetsDir  = "targets"
+	// maxArchiveFileSize bounds the size of the files read from an archive.
+	maxArchiveFileSize = 32 << 20
+)
+
+// archiveRoles are the roles whose metadata is exported.
+var archiveRoles = []string{"root", "timestamp", "snapshot", "targets"}
+
+// ExportArchive writes the trusted top-level metadata of an initialized client,
+// and the given top-level targets, to w as a gzipped tarball, to carry the
+// state of the repository across an air gap. Targets that are not cached are
+// retrieved first. The archive holds metadata/<role>.json and
+// targets/<target name> files, along with metadata/<version>.root.json files
+// for the previous versions of root.json the client walked, through which the
+// importer checks that the root.json chains from the one it trusts.
+func (s *SigstoreTufClient) ExportArchive(ctx context.Context, w io.Writer, targets []string) error {
+	v, err := s.view()
+	if err != nil {
+		return err
+	}
+	defer s.persist(v)
+	files, err := s.targets(ctx, v)
+	if err != nil {
+		return err
+	}
+	meta, err := v.local.GetMeta()
+	if err != nil {
+		return fmt.Errorf("reading local metadata: %w", err)
+	}
+	version, err := rootVersion(v.local)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	writeFile := func(name string, b []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(b)),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	for _, role := range archiveRoles {
+		b, ok := meta[role+".json"]
+		if !ok {
+			return fmt.Errorf("no local %s.json", role)
+		}
+		if err := writeFile(path.Join(archiveMetadataDir, role+".json"), b); err != nil {
+			return fmt.Errorf("writing archive: %w", err)
+		}
+	}
+	for prev := int64(1); prev < version; prev++ {
+		b, err := v.files.readFile(rootFileName(prev))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := writeFile(path.Join(archiveMetadataDir, archiveRootName(prev)), b); err != nil {
+			return fmt.Errorf("writing archive: %w", err)
+		}
+	}
+	names := append([]string(nil), targets...)
+	sort.Strings(names)
+	for _, name := range names {
+		name = util.NormalizeTarget(name)
+		if _, ok := files[name]; !ok {
+			return fmt.Errorf("target %s is not a top-level target", name)
+		}
+		b, err := s.getTarget(ctx, v, name)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(path.Join(archiveTargetsDir, name), b); err != nil {
+			return fmt.Errorf("writing archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	return nil
+}
+
+// ImportArchive creates a client in offline mode from an archive written by
+// ExportArchive. The root.json of the archive must be the trusted root.json
+// of repoOpts, or chain from it through the previous versions in the archive.
+// The rest of the metadata is verified against it, and the targets against
+// the targets metadata, as in offline mode, before they are stored in the
+// local store configured by opts. The metadata may have expired by up to
+// opts.OfflineGracePeriod.
+// With a Disk cache, the archive replaces the content of the subdirectory of
+// the cache location named after the repository, which defaults to the digest
+// of the trusted root.json, under the lock held by updates. A client initialized later with the same cache and
+// repository options may update the imported metadata from a remote.
+func ImportArchive(r io.Reader, opts *ClientOptions, repoOpts *RepositoryOptions) (*SigstoreTufClient, error) {
+	if len(repoOpts.Root) == 0 {
+		return nil, errors.New("importing archive: a trusted root.json is required")
+	}
+	repoName, err := repositoryName(repoOpts)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts := *opts
+	clientOpts.Offline = true
+	// The archive is verified before it reaches the configured cache.
+	stagedFiles := newMemoryFileStore()
+	staging := &SigstoreTufClient{local: newMemoryStore(), files: stagedFiles, opts: clientOpts}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	targets := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, maxArchiveFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if len(b) > maxArchiveFileSize {
+			return nil, fmt.Errorf("archive file %s is too large", hdr.Name)
+		}
+		dir, name, _ := strings.Cut(hdr.Name, "/")
+		switch {
+		case dir == archiveMetadataDir && isArchiveRole(name):
+			if err := staging.local.SetMeta(name, b); err != nil {
+				return nil, err
+			}
+		case dir == archiveMetadataDir && isArchiveRootVersion(name):
+			// The chain is verified with the version in the name.
+			version, _ := strconv.ParseInt(strings.TrimSuffix(name, ".root.json"), 10, 64)
+			if err := stagedFiles.writeFile(rootFileName(version), b); err != nil {
+				return nil, err
+			}
+		case dir == archiveTargetsDir && name != "" && path.Clean(name) == name:
+			if _, ok := targets[name]; ok {
+				return nil, fmt.Errorf("duplicate target %s in archive", name)
+			}
+			targets[name] = b
+		default:
+			return nil, fmt.Errorf("unexpected file %s in archive", hdr.Name)
+		}
+	}
+
+	m, err := staging.loadOfflineMetadata(staging.local, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("verifying archive: %w", err)
+	}
+	staged, err := staging.local.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRootChain(repoOpts.Root, staged["root.json"], stagedFiles); err != nil {
+		return nil, fmt.Errorf("verifying archive: %w", err)
+	}
+	for name, b := range targets {
+		meta, ok := m.targets.Targets[name]
+		if !ok {
+			return nil, fmt.Errorf("verifying archive: unknown target %s", name)
+		}
+		if err := util.BytesMatchLenAndHashes(b, meta.Length, meta.Hashes); err != nil {
+			return nil, fmt.Errorf("verifying archive: target %s: %w", name, err)
+		}
+		if err := stagedFiles.writeFile(targetFileName(name), b); err != nil {
+			return nil, err
+		}
+	}
+
+	local, files, err := localStoreFromOpts(&clientOpts, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if shared, ok := local.(sharedStore); ok {
+		// Other processes sharing the cache wait for the import, which
+		// replaces the metadata and files of the repository entirely.
+		unlock, err := shared.lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if err := shared.clear(); err != nil {
+			return nil, fmt.Errorf("storing archive: %w", err)
+		}
+	}
+	for name, b := range staged {
+		if err := local.SetMeta(name, b); err != nil {
+			return nil, fmt.Errorf("storing archive: %w", err)
+		}
+	}
+	for name, b := range stagedFiles.files {
+		if err := files.writeFile(name, b); err != nil {
+			return nil, fmt.Errorf("storing archive: %w", err)
+		}
+	}
+	return &SigstoreTufClient{
+		local:       local,
+		files:       files,
+		repoName:    repoName,
+		opts:        clientOpts,
+		repoOpts:    repoOpts,
+		initialized: true,
+	}, nil
+}
+
+func isArchiveRole(name string) bool {
+	for _, role := range archiveRoles {
+		if name == role+".json" {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveRootName returns the name of a previous version of root.json in an
+// archive.
+func archiveRootName(version int64) string {
+	return fmt.Sprintf("%d.root.json", version)
+}
+
+func isArchiveRootVersion(name string) bool {
+	if !strings.HasSuffix(name, ".root.json") {
+		return false
+	}
+	version, err := strconv.ParseInt(strings.TrimSuffix(name, ".root.json"), 10, 64)
+	return err == nil && version > 0 && archiveRootName(version) == name
+}
@@ -0,0 +1,259 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+// readArchive returns the files of an archive.
+func readArchive(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files[hdr.Name], err = io.ReadAll(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeArchive returns an archive of the files.
+func writeArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, b := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.AddTarget("other.txt", []byte("other"), nil)
+	testRepo.Publish()
+	rootV1 := testRepo.Root()
+	client := newInitializedClient(t, td, rootV1, &ClientOptions{CacheType: Memory})
+	// The importer trusts the first root.json, while the archive holds the
+	// third.
+	for i := 0; i < 2; i++ {
+		testRepo.RotateKeys("root")
+		testRepo.Publish()
+	}
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.ExportArchive(context.Background(), io.Discard, []string{"missing.txt"}); err == nil {
+		t.Error("ExportArchive returned, expected error for a missing target")
+	}
+	var buf bytes.Buffer
+	if err := client.ExportArchive(context.Background(), &buf, []string{TrustedRootTarget}); err != nil {
+		t.Fatalf("ExportArchive unexpectedly returned an error: %v", err)
+	}
+	archive := buf.Bytes()
+	if files := readArchive(t, archive); len(files) != 7 || files["targets/"+TrustedRootTarget] == nil || files["metadata/2.root.json"] == nil {
+		t.Fatalf("unexpected archive files %v", files)
+	}
+
+	testCases := []struct {
+		name    string
+		modify  func(files map[string][]byte)
+		wantErr bool
+	}{
+		{
+			name:   "unmodified",
+			modify: func(files map[string][]byte) {},
+		},
+		{
+			name: "tampered target",
+			modify: func(files map[string][]byte) {
+				files["targets/"+TrustedRootTarget] = []byte("{}")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown target",
+			modify: func(files map[string][]byte) {
+				files["targets/unknown.txt"] = []byte("unknown")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tampered metadata",
+			modify: func(files map[string][]byte) {
+				files["metadata/targets.json"] = bytes.Replace(files["metadata/targets.json"], []byte("other.txt"), []byte("tampered"), 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing metadata",
+			modify: func(files map[string][]byte) {
+				delete(files, "metadata/snapshot.json")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unexpected file",
+			modify: func(files map[string][]byte) {
+				files["metadata/delegated.json"] = []byte("{}")
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing root version",
+			modify: func(files map[string][]byte) {
+				delete(files, "metadata/2.root.json")
+			},
+			wantErr: true,
+		},
+		{
+			name: "misnamed root version",
+			modify: func(files map[string][]byte) {
+				files["metadata/2.root.json"] = files["metadata/1.root.json"]
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			files := readArchive(t, archive)
+			tc.modify(files)
+			imported, err := ImportArchive(bytes.NewReader(writeArchive(t, files)), &ClientOptions{
+				CacheType:     Disk,
+				CacheLocation: filepath.Join(t.TempDir(), "cache"),
+			}, &RepositoryOptions{Root: rootV1})
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("ImportArchive unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("ImportArchive returned, expected error")
+			}
+			if _, err := imported.GetTrustedRoot(context.Background()); err != nil {
+				t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+			}
+			if _, err := imported.GetTarget(context.Background(), "other.txt"); err == nil {
+				t.Error("GetTarget returned, expected error for a target that was not exported")
+			}
+			// The imported chain is checked again offline.
+			if err := imported.Refresh(context.Background()); err != nil {
+				t.Errorf("Refresh unexpectedly returned an error: %v", err)
+			}
+		})
+	}
+
+	// An archive of a repository signed with other keys is rejected.
+	otherRepo := tuftest.NewRepository(t)
+	otherRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	otherRepo.Publish()
+	other := newInitializedClient(t, otherRepo.Dir(), otherRepo.Root(), &ClientOptions{CacheType: Memory})
+	var otherArchive bytes.Buffer
+	if err := other.ExportArchive(context.Background(), &otherArchive, []string{TrustedRootTarget}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportArchive(&otherArchive, &ClientOptions{CacheType: Memory}, &RepositoryOptions{Root: rootV1}); err == nil {
+		t.Error("ImportArchive returned, expected error for an archive signed with other keys")
+	}
+	if _, err := ImportArchive(bytes.NewReader(archive), &ClientOptions{CacheType: Memory}, &RepositoryOptions{}); err == nil {
+		t.Error("ImportArchive returned, expected error without a trusted root.json")
+	}
+}
+
+func TestImportArchiveReplacesCache(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.AddTarget("other.txt", []byte("other"), nil)
+	testRepo.Delegate("targets", "team", []string{"team/*"}, false)
+	testRepo.AddTargetToRole("team", "team/artifact.txt", []byte("artifact"), nil)
+	testRepo.Publish()
+	opts := &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: filepath.Join(t.TempDir(), "cache"),
+	}
+	client := newInitializedClient(t, td, testRepo.Root(), opts)
+	if _, err := client.GetTarget(context.Background(), "other.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTarget(context.Background(), "team/artifact.txt"); err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(opts.CacheLocation, "sigstore-staging")
+	if _, err := os.Stat(filepath.Join(repoDir, "team.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := client.ExportArchive(context.Background(), &buf, []string{TrustedRootTarget}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportArchive(&buf, opts, &RepositoryOptions{Name: "sigstore-staging", Root: testRepo.Root()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "team.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the delegated metadata missing from the archive to be removed, got %v", err)
+	}
+	if _, err := imported.GetTarget(context.Background(), "other.txt"); err == nil {
+		t.Error("GetTarget returned, expected error for a target missing from the archive")
+	}
+	if _, err := imported.GetTrustedRoot(context.Background()); err != nil {
+		t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+}
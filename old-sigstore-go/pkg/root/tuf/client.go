@@ -16,73 +16,486 @@
 package tuf
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
 )
 
+// TrustedRootTarget is the name of the TUF target containing the trusted root.
+const TrustedRootTarget = "trusted_root.json"
+
+// SigningConfigTarget is the name of the TUF target containing the signing
+// configuration.
+const SigningConfigTarget = "signing_config.v0.2.json"
+
+var _ root.TrustedRootProvider = (*SigstoreTufClient)(nil)
+
 // This is a SigstoreTufClient. Note that this is not opinionated on
 // its usage and does not include a sync.Once for single intialization. Users
 // of the library are responsible for considering its usage in their application.
 // It is threadsafe.
 type SigstoreTufClient struct {
-	// TODO: Add concurrency support for load operations.
+	// local is the TUF local repository for accessing local trusted metadata.
+	// It is always served from memory, and a Disk cache is synced to the
+	// configured cache location during updates. It is created by Initialize
+	// for the repository named repoName, along with files, which holds the
+	// verified targets and previous versions of root.json.
+	local    client.LocalStore
+	files    fileStore
+	repoName string
 
-	// client is the base TUF client.
-	// TODO: Replace when go-tuf implements a TAP-4 multi-repository client.
-	// https://github.com/theupdateframework/go-tuf/issues/348, then this
-	// will be an interface.
-	client *client.Client
+	// opts are the options the client was created with.
+	opts ClientOptions
 
-	// local is the TUF local repository for accessing local trusted metadata.
-	// TODO: As an optimization, use an in-memory store always, and sync to a
-	// configured cache location during updates.
-	local client.LocalStore
+	// repoOpts are the options of the initialized repository, used to reach
+	// the remote on every operation.
+	repoOpts *RepositoryOptions
 
 	// initialized detects whether a remote repository was configured into the
 	// TUF client.
 	initialized bool
+
+	// rootChain reports the chain of root.json versions walked by the last
+	// update.
+	rootChain *RootChainReport
+
+	// generation counts the updates of the local metadata, so that reads
+	// only write back the metadata they downloaded if there was none since.
+	generation uint64
+
+	// trustedRoot is the trusted root retrieved after the last update, if
+	// there were subscribers to compare it with the next one.
+	trustedRoot *root.TrustedRoot
+
+	// updateMu is held for writing while the local metadata is updated, and
+	// for reading while it is read or copied, so that readers never observe a
+	// partial update. It guards local, files, repoName, repoOpts,
+	// initialized, rootChain, generation and trustedRoot.
+	updateMu sync.RWMutex
+
+	// mirrors tracks the failures of the remote and mirrors.
+	mirrors mirrorHealth
+
+	// refreshes coalesces concurrent calls to Refresh.
+	refreshes refreshGroup
+
+	// mu guards the subscribers.
+	mu          sync.Mutex
+	subscribers map[int]func(UpdateEvent)
+	nextID      int
 }
 
 // NewSigstoreTufClient creates a new client given client options.
 func NewSigstoreTufClient(opts *ClientOptions) (*SigstoreTufClient, error) {
-	local, err := localStoreFromOpts(opts)
-	if err != nil {
+	if opts.Offline && opts.CacheType != Disk {
+		return nil, errOfflineRequiresDisk
+	}
+	if err := checkCacheOptions(opts); err != nil {
 		return nil, err
 	}
-	return &SigstoreTufClient{local: local}, nil
+	return &SigstoreTufClient{opts: *opts}, nil
+}
+
+// newClient creates a base TUF client whose requests to the remote are bound
+// to ctx. The client loads its trusted metadata from local.
+func (s *SigstoreTufClient) newClient(ctx context.Context, local client.LocalStore, opts *RepositoryOptions) (*client.Client, error) {
+	remote, err := remoteStoreFromOpts(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("remoteStoreFromOpts: %w", err)
+	}
+	return client.NewClient(local, remote), nil
 }
 
 // Initialize initializes the Sigstore TUF Client given a particular repository.
 // This WILL run a network call to the remote. The remote may be configured to a
 // local filesystem.
-// If you intend to load in TrustedRootStore information from fixed information,
+// The network calls are bound to ctx, which may be used to cancel them or set
+// a deadline.
+// If you intend to load in TrustedRoot information from fixed information,
 // create a new provider.
-func (s *SigstoreTufClient) Initialize(opts *RepositoryOptions) error {
-	remote, err := remoteStoreFromOpts(opts)
+// The mirrors of the repository are tried in turn when the remote fails.
+// With a Disk cache, the repository is cached in the subdirectory of the cache
+// location named after it. The update holds an advisory lock on it, and
+// cached metadata that is not well-formed is removed beforehand, so that the
+// client bootstraps again from the trusted root.json.
+// The chain of root.json versions walked from the trusted root.json is
+// reported by RootChain.
+// In offline mode, the cached metadata is verified instead, and no network
+// call is made.
+func (s *SigstoreTufClient) Initialize(ctx context.Context, opts *RepositoryOptions) error {
+	name, err := repositoryName(opts)
 	if err != nil {
-		return fmt.Errorf("remoteStoreFromOpts: %w", err)
+		return err
 	}
-	s.client = client.NewClient(s.local, remote)
-	if err := s.client.Init(opts.Root); err != nil {
-		return fmt.Errorf("initializing Sigstore TUF client: %w", err)
+	s.updateMu.Lock()
+	wasInitialized := s.initialized && name == s.repoName
+	// A repository with another name is updated in its own store, which
+	// replaces the current one only once the update succeeds.
+	local, files := s.local, s.files
+	if local == nil || name != s.repoName {
+		if local, files, err = localStoreFromOpts(&s.opts, name); err != nil {
+			s.updateMu.Unlock()
+			return err
+		}
 	}
-	// Update with the TUF client.
-	if _, err := s.client.Update(); err != nil {
-		return fmt.Errorf("updating Sigstore TUF client: %w", err)
+	watch := s.watching()
+	var oldRoot *root.TrustedRoot
+	if watch && wasInitialized {
+		oldRoot = s.previousTrustedRoot(ctx)
 	}
-	s.initialized = true
-	return nil
+	oldVersion, newVersion, err := s.update(ctx, local, files, name, opts, true)
+	var event *UpdateEvent
+	if err == nil {
+		s.local, s.files, s.repoName = local, files, name
+		s.repoOpts = opts
+		s.initialized = true
+		if watch {
+			event, err = s.updateEvent(ctx, wasInitialized, oldVersion, newVersion, oldRoot)
+		}
+	}
+	s.updateMu.Unlock()
+	if event != nil {
+		s.notify(*event)
+	}
+	return err
 }
 
-// GetTrustedRoot returns a TrustedRootStore that can be ingested by verifiers.
-func (s *SigstoreTufClient) GetTrustedRootStore() error {
+// Refresh updates the trusted metadata of an initialized client from the
+// remote. It is safe to call concurrently with other calls to Refresh and with
+// reads: concurrent calls wait for a single update, bound to the context of
+// the call that started it, and reads observe the metadata either before or
+// after the update.
+// In offline mode, the cached metadata is verified again.
+func (s *SigstoreTufClient) Refresh(ctx context.Context) error {
+	return s.refreshes.do(ctx, s.refresh)
+}
+
+func (s *SigstoreTufClient) refresh(ctx context.Context) error {
+	s.updateMu.Lock()
 	if !s.initialized {
-		// unexpected
+		s.updateMu.Unlock()
 		return errors.New("sigstore TUF client must be initialized before usage")
 	}
+	watch := s.watching()
+	var oldRoot *root.TrustedRoot
+	if watch {
+		oldRoot = s.previousTrustedRoot(ctx)
+	}
+	oldVersion, newVersion, err := s.update(ctx, s.local, s.files, s.repoName, s.repoOpts, false)
+	var event *UpdateEvent
+	if err == nil && watch {
+		event, err = s.updateEvent(ctx, true, oldVersion, newVersion, oldRoot)
+	}
+	s.updateMu.Unlock()
+	if event != nil {
+		s.notify(*event)
+	}
+	return err
+}
+
+// refreshCall is a refresh in flight.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// refreshGroup coalesces concurrent refreshes: a call waits for the refresh
+// in flight, if any, instead of starting another.
+type refreshGroup struct {
+	mu      sync.Mutex
+	current *refreshCall
+}
+
+// do calls refresh with ctx, or waits for the refresh in flight until ctx is
+// done, and returns its error.
+func (g *refreshGroup) do(ctx context.Context, refresh func(context.Context) error) error {
+	g.mu.Lock()
+	call := g.current
+	if call == nil {
+		call = &refreshCall{done: make(chan struct{})}
+		g.current = call
+		g.mu.Unlock()
+
+		call.err = refresh(ctx)
+		g.mu.Lock()
+		g.current = nil
+		g.mu.Unlock()
+		close(call.done)
+		return call.err
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update brings the metadata of the named repository in local up to date with
+// the remote, and returns the versions of root.json before and after. The
+// trusted root.json of opts is installed first when init is set, or when
+// local does not hold one, and the root.json versions walked are stored in
+// files. The caller must hold updateMu for writing.
+func (s *SigstoreTufClient) update(ctx context.Context, local client.LocalStore, files fileStore, name string, opts *RepositoryOptions, init bool) (oldVersion, newVersion int64, err error) {
+	s.generation++
+	s.trustedRoot = nil
+	if s.opts.Offline {
+		if _, err := s.loadOfflineMetadata(local, time.Now()); err != nil {
+			return 0, 0, fmt.Errorf("loading offline Sigstore TUF client: %w", err)
+		}
+		// The cached metadata is only trusted if it chains from the
+		// trusted root.json of opts.
+		meta, err := local.GetMeta()
+		if err != nil {
+			return 0, 0, fmt.Errorf("reading local metadata: %w", err)
+		}
+		if err := verifyRootChain(opts.Root, meta["root.json"], files); err != nil {
+			return 0, 0, fmt.Errorf("loading offline Sigstore TUF client: %w", err)
+		}
+		return 0, 0, nil
+	}
+	c, err := s.newClient(ctx, local, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if shared, ok := local.(sharedStore); ok {
+		// Other processes sharing the cache wait for the update, and
+		// corrupted metadata is downloaded again.
+		unlock, err := shared.lock()
+		if err != nil {
+			return 0, 0, err
+		}
+		defer unlock()
+		if err := shared.repair(); err != nil {
+			return 0, 0, fmt.Errorf("repairing TUF cache: %w", err)
+		}
+	}
+	oldVersion, err = rootVersion(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	if init || oldVersion == 0 {
+		if err := c.Init(opts.Root); err != nil {
+			return 0, 0, fmt.Errorf("initializing Sigstore TUF client: %w", err)
+		}
+	}
+	meta, err := local.GetMeta()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading local metadata: %w", err)
+	}
+	trustedRoot := meta["root.json"]
+	// Update with the TUF client, falling back to the mirrors, recording the
+	// root.json versions walked.
+	log := &rootLog{}
+	if err := s.withMirrors(ctx, &rootRecorder{LocalStore: local, log: log}, opts, func(c *client.Client) error {
+		if _, err := c.Update(); err != nil {
+			return contextError(ctx, err)
+		}
+		return nil
+	}); err != nil {
+		return 0, 0, fmt.Errorf("updating Sigstore TUF client: %w", err)
+	}
+	newVersion, err = rootVersion(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s.rootChain, err = newRootChainReport(name, trustedRoot, log.roots); err != nil {
+		return 0, 0, err
+	}
+	// The chain is kept for offline mode to check the cache against the
+	// trusted root.json.
+	if err := storeRootHistory(files, append([]json.RawMessage{trustedRoot}, log.roots...)); err != nil {
+		return 0, 0, err
+	}
+	return oldVersion, newVersion, nil
+}
+
+// GetTrustedRoot returns the TrustedRoot distributed in the repository, which
+// can be ingested by verifiers. The target is retrieved as by GetTarget.
+func (s *SigstoreTufClient) GetTrustedRoot(ctx context.Context) (*root.TrustedRoot, error) {
+	v, err := s.view()
+	if err != nil {
+		return nil, err
+	}
+	defer s.persist(v)
+	return s.fetchTrustedRoot(ctx, v)
+}
+
+func (s *SigstoreTufClient) fetchTrustedRoot(ctx context.Context, v *view) (*root.TrustedRoot, error) {
+	targets, err := s.targets(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := targets[TrustedRootTarget]; !ok {
+		// trusted_root.json may be delegated to another role, while
+		// repositories predating it publish each trust anchor as a
+		// separate target.
+		var notFound client.ErrNotFound
+		if _, err := s.resolveTarget(ctx, v, TrustedRootTarget); errors.As(err, &notFound) {
+			return s.getLegacyTrustedRoot(ctx, v, targets)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	rootJSON, err := s.getTarget(ctx, v, TrustedRootTarget)
+	if err != nil {
+		return nil, err
+	}
+	return root.NewTrustedRootFromJSON(rootJSON)
+}
+
+// getLegacyTrustedRoot assembles the TrustedRoot from the legacy targets of
+// the repository, such as fulcio.crt.pem and rekor.pub, identified by their
+// "sigstore" custom metadata.
+func (s *SigstoreTufClient) getLegacyTrustedRoot(ctx context.Context, v *view, targets data.TargetFiles) (*root.TrustedRoot, error) {
+	names := legacyTargetNames(targets)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("repository has neither %s nor legacy targets", TrustedRootTarget)
+	}
+	legacyTargets := make([]legacyTarget, 0, len(names))
+	for _, name := range names {
+		// The custom metadata was validated when listing the targets.
+		custom, _ := parseLegacyCustomMetadata(targets[name].Custom)
+		b, err := s.getTarget(ctx, v, name)
+		if err != nil {
+			return nil, err
+		}
+		legacyTargets = append(legacyTargets, legacyTarget{
+			name:   name,
+			usage:  custom.Sigstore.Usage,
+			status: custom.Sigstore.Status,
+			uri:    custom.Sigstore.URI,
+			data:   b,
+		})
+	}
+	pb, err := legacyTrustedRoot(legacyTargets, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("assembling trusted root from legacy targets: %w", err)
+	}
+	return root.NewTrustedRootFromProtobuf(pb)
+}
+
+// targets lists the top-level targets of the trusted metadata of v.
+func (s *SigstoreTufClient) targets(ctx context.Context, v *view) (data.TargetFiles, error) {
+	if s.opts.Offline {
+		return s.offlineTargets(v.local)
+	}
+	c, err := s.newClient(ctx, v.local, v.opts)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := c.Targets()
+	if err != nil {
+		return nil, fmt.Errorf("listing targets: %w", err)
+	}
+	return targets, nil
+}
+
+// view is the state of an initialized client that a read works on: a copy of
+// the local metadata, and the options of the repository. It is taken under
+// updateMu, so that the read downloads targets and delegated metadata without
+// holding it.
+type view struct {
+	generation uint64
+	local      client.LocalStore
+	// base is the metadata as copied, to find what the read downloaded.
+	base  map[string]json.RawMessage
+	files fileStore
+	opts  *RepositoryOptions
+}
 
-	// This will check the local trusted metadata and assemble the TrustedRootStore
-	return errors.New("unimplemented")
+// view copies the state of an initialized client for a read.
+func (s *SigstoreTufClient) view() (*view, error) {
+	s.updateMu.RLock()
+	defer s.updateMu.RUnlock()
+	if !s.initialized {
+		return nil, errors.New("sigstore TUF client must be initialized before usage")
+	}
+	meta, err := s.local.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("reading local metadata: %w", err)
+	}
+	local := newMemoryStore()
+	for name, b := range meta {
+		local.meta[name] = b
+	}
+	v := s.lockedView()
+	v.local, v.base = local, meta
+	return v, nil
+}
+
+// lockedView returns the state of the client for a read that holds
+// updateMu, and works on the local store itself.
+func (s *SigstoreTufClient) lockedView() *view {
+	return &view{
+		generation: s.generation,
+		local:      s.local,
+		files:      s.files,
+		opts:       s.repoOpts,
+	}
+}
+
+// persist writes the metadata a read downloaded into v back to the local
+// store, unless the local metadata was updated in the meantime. The metadata
+// was verified, so failing to persist it only costs a download on the next
+// read.
+func (s *SigstoreTufClient) persist(v *view) {
+	meta, _ := v.local.GetMeta()
+	downloaded := make(map[string]json.RawMessage)
+	for name, b := range meta {
+		if !bytes.Equal(v.base[name], b) {
+			downloaded[name] = b
+		}
+	}
+	if len(downloaded) == 0 {
+		return
+	}
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	if s.generation != v.generation {
+		return
+	}
+	for name, b := range downloaded {
+		_ = s.local.SetMeta(name, b)
+	}
+}
+
+// GetSigningConfig returns the SigningConfig distributed in the repository,
+// listing the service endpoints signers use. The target is retrieved with
+// GetTarget.
+func (s *SigstoreTufClient) GetSigningConfig(ctx context.Context) (*root.SigningConfig, error) {
+	configJSON, err := s.GetTarget(ctx, SigningConfigTarget)
+	if err != nil {
+		return nil, err
+	}
+	return root.NewSigningConfigFromJSON(configJSON)
+}
+
+// contextError returns the error of ctx if it is done, since the TUF client
+// does not wrap errors returned by the remote store.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// bufferDestination is an in-memory client.Destination.
+type bufferDestination struct {
+	bytes.Buffer
+}
+
+func (b *bufferDestination) Delete() error {
+	b.Reset()
+	return nil
 }
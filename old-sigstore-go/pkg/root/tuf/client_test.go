@@ -16,31 +16,36 @@
 package tuf
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
 )
 
-// TODO(asraa): Add support for:
-//   - expired timestamps and other repository states with embedded testdata.
-//   - concurrency
 func TestInitialize(t *testing.T) {
 	t.Parallel()
-	td := t.TempDir()
 
 	// Create a new TUF repository
-	testRepo := newTufRepository(t, td)
-	testRepo.addTarget("foo.txt", []byte("hello"), nil)
-	testRepo.publish()
-	rootBytes := testRepo.root()
-
-	// Serve remote repository.
-	s := httptest.NewServer(http.FileServer(http.Dir(filepath.Join(td, "repository"))))
-	t.Cleanup(func() {
-		s.Close()
-	})
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTarget("foo.txt", []byte("hello"), nil)
+	testRepo.Publish()
+	rootBytes := testRepo.Root()
+
+	// Create a repository whose timestamp has expired.
+	expiredRepo := tuftest.NewRepository(t)
+	expiredRepo.AddTarget("foo.txt", []byte("hello"), nil)
+	expiredRepo.Publish()
+	expiredRepo.SetExpires("timestamp", time.Now().Add(-time.Hour))
 
 	testCases := []struct {
 		name          string
@@ -75,10 +80,22 @@ func TestInitialize(t *testing.T) {
 			},
 			repoOpts: &RepositoryOptions{
 				Name:   "sigstore-staging",
-				Remote: s.URL,
+				Remote: testRepo.Serve(),
 				Root:   rootBytes,
 			},
 		},
+		{
+			name: "fail: expired timestamp",
+			tufOpts: &ClientOptions{
+				CacheType: Memory,
+			},
+			repoOpts: &RepositoryOptions{
+				Name:   "sigstore-staging",
+				Remote: expiredRepo.FileURL(),
+				Root:   expiredRepo.Root(),
+			},
+			wantInitErr: true,
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -94,16 +111,298 @@ func TestInitialize(t *testing.T) {
 			if tc.wantClientErr {
 				t.Fatalf("NewSigstoreTufClient returned, expected error: %v", tc.wantClientErr)
 			}
-			initErr := client.Initialize(tc.repoOpts)
+			initErr := client.Initialize(context.Background(), tc.repoOpts)
 			if initErr != nil {
 				if !tc.wantInitErr {
 					t.Fatalf("Initialize unexpectedly returned an error: %v", initErr)
 				}
 				return
 			}
-			if tc.wantClientErr {
+			if tc.wantInitErr {
 				t.Fatalf("Initialize returned, expected error: %v", tc.wantInitErr)
 			}
 		})
 	}
 }
+
+func TestGetTrustedRoot(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTrustedRoot(context.Background()); err == nil {
+		t.Fatal("GetTrustedRoot returned, expected error before initialization")
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: fmt.Sprintf("file://%s/repository", td),
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := client.GetTrustedRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if len(tr.TransparencyLogs()) != 1 {
+		t.Errorf("expected 1 transparency log, got %d", len(tr.TransparencyLogs()))
+	}
+}
+
+func TestGetSigningConfig(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTarget(SigningConfigTarget, []byte(`{
+		"mediaType": "application/vnd.dev.sigstore.signingconfig.v0.2+json",
+		"caUrls": [{"url": "https://fulcio.sigstore.dev", "majorApiVersion": 1, "validFor": {"start": "2023-01-01T00:00:00Z"}}],
+		"rekorTlogUrls": [{"url": "https://rekor.sigstore.dev", "majorApiVersion": 1, "validFor": {"start": "2023-01-01T00:00:00Z"}}],
+		"rekorTlogConfig": {"selector": "ANY"}
+	}`), nil)
+	testRepo.Publish()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: fmt.Sprintf("file://%s/repository", td),
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := client.GetSigningConfig(context.Background())
+	if err != nil {
+		t.Fatalf("GetSigningConfig unexpectedly returned an error: %v", err)
+	}
+	ca, err := root.SelectService(sc.CertificateAuthorityURLs, []uint32{1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.URL != "https://fulcio.sigstore.dev" {
+		t.Errorf("expected Fulcio URL, got %s", ca.URL)
+	}
+	if _, err := client.GetTrustedRoot(context.Background()); err == nil {
+		t.Error("GetTrustedRoot returned, expected error for missing target")
+	}
+}
+
+func TestInitializeFailure(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTarget("foo.txt", []byte("foo"), nil)
+	testRepo.Publish()
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{CacheType: Memory})
+
+	// Initializing another repository with a root.json that does not match
+	// the remote fails, and leaves the client on the previous repository.
+	otherRepo := tuftest.NewRepository(t)
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "prod",
+		Remote: fmt.Sprintf("file://%s/repository", td),
+		Root:   otherRepo.Root(),
+	}); err == nil {
+		t.Fatal("Initialize returned, expected error for a root.json that does not match the remote")
+	}
+	if b, err := client.GetTarget(context.Background(), "foo.txt"); err != nil || string(b) != "foo" {
+		t.Errorf("GetTarget returned %q, %v after a failed Initialize", b, err)
+	}
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Errorf("Refresh unexpectedly returned an error after a failed Initialize: %v", err)
+	}
+}
+
+func TestInitializeContext(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+
+	// The remote hangs until the client gives up.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(s.Close)
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.Initialize(ctx, &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: s.URL,
+		Root:   testRepo.Root(),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Initialize returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Refresh(context.Background()); err == nil {
+		t.Error("Refresh returned, expected error for uninitialized client")
+	}
+
+	// The first refresh after initialization hangs until released, while
+	// other calls pile up.
+	var timestampRequests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	files := http.FileServer(http.Dir(filepath.Join(td, "repository")))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/timestamp.json" && atomic.AddInt32(&timestampRequests, 1) == 2 {
+			close(started)
+			<-release
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: s.URL,
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	go func() {
+		defer wg.Done()
+		if err := client.Refresh(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-started
+	waiting := make(chan struct{})
+	for i := 1; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			ctx := &waitingContext{Context: context.Background(), waiting: waiting}
+			if err := client.Refresh(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// The remote is released once all other calls wait for the update.
+	for i := 1; i < callers; i++ {
+		select {
+		case <-waiting:
+		case <-time.After(5 * time.Second):
+			close(release)
+			t.Fatal("concurrent calls did not wait for the update in flight")
+		}
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&timestampRequests); n != 2 {
+		t.Errorf("expected concurrent refreshes to share 1 update, got %d", n-1)
+	}
+
+	// A waiting call gives up with its context.
+	block := make(chan struct{})
+	defer close(block)
+	client.refreshes.mu.Lock()
+	client.refreshes.current = &refreshCall{done: block}
+	client.refreshes.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := client.Refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Refresh returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+// waitingContext signals when a call first waits on it.
+type waitingContext struct {
+	context.Context
+	waiting chan<- struct{}
+	once    sync.Once
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { c.waiting <- struct{}{} })
+	return c.Context.Done()
+}
+
+func TestRefreshConcurrentReads(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: filepath.Join(t.TempDir(), "cache"),
+	})
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+				targets, err := client.ListTargets(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// Targets are only added, one per update.
+				if len(targets) < seen {
+					t.Errorf("listed %d targets after %d", len(targets), seen)
+					return
+				}
+				seen = len(targets)
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		testRepo.AddTarget(fmt.Sprintf("target-%d.txt", i), []byte("target"), nil)
+		testRepo.Publish()
+		if err := client.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	targets, err := client.ListTargets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 6 {
+		t.Errorf("expected 6 targets after refreshes, got %d", len(targets))
+	}
+}
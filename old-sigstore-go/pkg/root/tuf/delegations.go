@@ -0,0 +1,158 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/targets"
+	"github.com/theupdateframework/go-tuf/util"
+	"github.com/theupdateframework/go-tuf/verify"
+)
+
+// maxDelegations bounds the number of targets roles visited to resolve a
+// target, as by the TUF client.
+const maxDelegations = 32
+
+// ResolvedTarget is a target resolved through the delegations of the
+// repository.
+type ResolvedTarget struct {
+	TargetInfo
+	// Role is the targets role whose metadata lists the target, and whose
+	// keys signed its length and hashes: "targets" for a top-level target.
+	Role string `json:"role"`
+	// Delegations are the roles delegating the target, from the top-level
+	// targets role to Role.
+	Delegations []string `json:"delegations"`
+}
+
+// ResolveTarget looks up a target through the delegations of the repository
+// and reports the role that signed it. Roles are searched as by the TUF
+// client: depth-first in the order they are delegated, following only the
+// delegations whose paths or path hash prefixes match the name, and stopping
+// at the first terminating one. The delegated metadata is downloaded as
+// needed, while in offline mode it must have been cached online.
+func (s *SigstoreTufClient) ResolveTarget(ctx context.Context, name string) (*ResolvedTarget, error) {
+	v, err := s.view()
+	if err != nil {
+		return nil, err
+	}
+	defer s.persist(v)
+	return s.resolveTarget(ctx, v, name)
+}
+
+// resolveTarget resolves a target with the metadata of v.
+func (s *SigstoreTufClient) resolveTarget(ctx context.Context, v *view, name string) (*ResolvedTarget, error) {
+	if !s.opts.Offline {
+		// The TUF client does not report the signing role, but it verifies
+		// the delegated metadata and persists it to v, where the
+		// delegations are walked again.
+		if _, err := s.lookupTarget(ctx, v, name); err != nil {
+			return nil, err
+		}
+	}
+	meta, path, err := s.resolveLocalTarget(v.local, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedTarget{
+		TargetInfo:  newTargetInfo(util.NormalizeTarget(name), meta),
+		Role:        path[len(path)-1],
+		Delegations: path,
+	}, nil
+}
+
+// resolveLocalTarget resolves a target through the metadata in local,
+// verified as in offline mode. It returns the metadata of the target and the
+// roles delegating it, from the top-level targets role to the one listing it.
+func (s *SigstoreTufClient) resolveLocalTarget(local client.LocalStore, name string, now time.Time) (data.TargetFileMeta, []string, error) {
+	name = util.NormalizeTarget(name)
+	m, err := s.loadOfflineMetadata(local, now)
+	if err != nil {
+		return data.TargetFileMeta{}, nil, err
+	}
+	meta, err := local.GetMeta()
+	if err != nil {
+		return data.TargetFileMeta{}, nil, fmt.Errorf("reading local metadata: %w", err)
+	}
+	delegations, err := targets.NewDelegationsIterator(name, m.db)
+	if err != nil {
+		return data.TargetFileMeta{}, nil, err
+	}
+	for i := 0; i < maxDelegations; i++ {
+		d, ok := delegations.Next()
+		if !ok {
+			return data.TargetFileMeta{}, nil, client.ErrNotFound{File: name}
+		}
+		t := m.targets
+		if d.Delegatee.Name != "targets" {
+			if t, err = s.delegatedTargets(meta, m.snapshot, d, now); err != nil {
+				return data.TargetFileMeta{}, nil, err
+			}
+		}
+		if target, ok := t.Targets[name]; ok {
+			path := []string{d.Delegatee.Name}
+			for role := d.Delegator; role != ""; role = delegations.Parent(role) {
+				path = append([]string{role}, path...)
+			}
+			return target, path, nil
+		}
+		if t.Delegations != nil && len(t.Delegations.Roles) > 0 {
+			db, err := verify.NewDBFromDelegations(t.Delegations)
+			if err != nil {
+				return data.TargetFileMeta{}, nil, fmt.Errorf("%s delegations: %w", d.Delegatee.Name, err)
+			}
+			delegations.Add(t.Delegations.Roles, d.Delegatee.Name, db)
+		}
+	}
+	return data.TargetFileMeta{}, nil, fmt.Errorf("target %s not found within %d delegations", name, maxDelegations)
+}
+
+// delegatedTargets verifies the metadata of a delegated role in the local
+// store against the keys of its delegator and the trusted snapshot.
+func (s *SigstoreTufClient) delegatedTargets(meta map[string]json.RawMessage, snapshot *data.Snapshot, d targets.Delegation, now time.Time) (*data.Targets, error) {
+	role := d.Delegatee.Name
+	fileName := role + ".json"
+	fileMeta, ok := snapshot.Meta[fileName]
+	if !ok {
+		return nil, fmt.Errorf("%s is not listed in the trusted snapshot", fileName)
+	}
+	b, ok := meta[fileName]
+	if !ok {
+		return nil, fmt.Errorf("no cached %s, the cache must be populated online first", fileName)
+	}
+	if len(fileMeta.Hashes) > 0 {
+		if err := util.BytesMatchLenAndHashes(b, fileMeta.Length, fileMeta.Hashes); err != nil {
+			return nil, fmt.Errorf("cached %s: %w", fileName, err)
+		}
+	}
+	t := &data.Targets{}
+	if err := d.DB.UnmarshalIgnoreExpired(b, t, role, fileMeta.Version); err != nil {
+		return nil, fmt.Errorf("verifying cached %s: %w", fileName, err)
+	}
+	if t.Version != fileMeta.Version {
+		return nil, fmt.Errorf("cached %s does not match the cached snapshot.json", fileName)
+	}
+	if err := s.checkOfflineExpiry(role, t.Expires, now); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,254 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// newDelegatedRepository publishes a repository delegating targets to teams,
+// with a nested, a terminating and hashed bin delegations.
+func newDelegatedRepository(t *testing.T) *tuftest.Repository {
+	t.Helper()
+	testRepo := tuftest.NewRepository(t)
+	testRepo.Delegate("targets", "team-a", []string{"teams/a/*"}, false)
+	testRepo.Delegate("team-a", "project", []string{"teams/a/project-*"}, false)
+	testRepo.Delegate("targets", "team-b", []string{"teams/b/*"}, false)
+	testRepo.Delegate("targets", "shared", []string{"teams/*/*"}, false)
+	// The terminating delegation is searched before the shared role, which
+	// can no longer sign for team b.
+	testRepo.Delegate("team-b", "locked", []string{"teams/b/*"}, true)
+	testRepo.DelegateHashBins("bin-", 2)
+
+	testRepo.AddTargetToRole("targets", "top.txt", []byte("top"), nil)
+	testRepo.AddTargetToRole("team-a", "teams/a/a.txt", []byte("a"), nil)
+	testRepo.AddTargetToRole("project", "teams/a/project-p.txt", []byte("p"), nil)
+	testRepo.AddTargetToRole("locked", "teams/b/b.txt", []byte("b"), nil)
+	testRepo.AddTargetToRole("shared", "teams/c/c.txt", []byte("c"), nil)
+	testRepo.AddTarget("binned.txt", []byte("binned"), nil)
+	testRepo.Publish()
+	return testRepo
+}
+
+func TestResolveTarget(t *testing.T) {
+	t.Parallel()
+	testRepo := newDelegatedRepository(t)
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	online := newInitializedClient(t, testRepo.Dir(), testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+
+	testCases := []struct {
+		name            string
+		target          string
+		wantDelegations []string
+		wantErr         bool
+	}{
+		{
+			name:            "top-level target",
+			target:          "top.txt",
+			wantDelegations: []string{"targets"},
+		},
+		{
+			name:            "delegated target",
+			target:          "teams/a/a.txt",
+			wantDelegations: []string{"targets", "team-a"},
+		},
+		{
+			name:            "nested delegation",
+			target:          "teams/a/project-p.txt",
+			wantDelegations: []string{"targets", "team-a", "project"},
+		},
+		{
+			name:            "terminating delegation",
+			target:          "teams/b/b.txt",
+			wantDelegations: []string{"targets", "team-b", "locked"},
+		},
+		{
+			name:    "unknown target behind a terminating delegation",
+			target:  "teams/b/unknown.txt",
+			wantErr: true,
+		},
+		{
+			name:            "delegation searched after another",
+			target:          "teams/c/c.txt",
+			wantDelegations: []string{"targets", "shared"},
+		},
+		{
+			name:    "unknown target",
+			target:  "teams/a/unknown.txt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := online.ResolveTarget(context.Background(), tc.target)
+			if tc.wantErr {
+				var notFound client.ErrNotFound
+				if !errors.As(err, &notFound) {
+					t.Fatalf("ResolveTarget returned %v, expected not found error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveTarget unexpectedly returned an error: %v", err)
+			}
+			if target.Name != tc.target || target.Role != tc.wantDelegations[len(tc.wantDelegations)-1] {
+				t.Errorf("unexpected target %+v", target)
+			}
+			if !reflect.DeepEqual(target.Delegations, tc.wantDelegations) {
+				t.Errorf("expected delegations %v, got %v", tc.wantDelegations, target.Delegations)
+			}
+			if _, err := online.GetTarget(context.Background(), tc.target); err != nil {
+				t.Errorf("GetTarget unexpectedly returned an error: %v", err)
+			}
+		})
+	}
+
+	// Hashed bins are delegated all paths.
+	binned, err := online.ResolveTarget(context.Background(), "binned.txt")
+	if err != nil {
+		t.Fatalf("ResolveTarget unexpectedly returned an error: %v", err)
+	}
+	if !strings.HasPrefix(binned.Role, "bin-") || len(binned.Delegations) != 2 {
+		t.Errorf("expected a hashed bin to sign binned.txt, got %+v", binned)
+	}
+	if b, err := online.GetTarget(context.Background(), "binned.txt"); err != nil || string(b) != "binned" {
+		t.Errorf("GetTarget returned %q, %v", b, err)
+	}
+
+	// The cached delegated metadata resolves the same targets offline.
+	offline := newInitializedClient(t, testRepo.Dir(), testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+		Offline:       true,
+	})
+	for _, tc := range testCases {
+		target, err := offline.ResolveTarget(context.Background(), tc.target)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: ResolveTarget returned, expected error offline", tc.name)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(target.Delegations, tc.wantDelegations) {
+			t.Errorf("%s: ResolveTarget returned %+v, %v offline", tc.name, target, err)
+		}
+		if _, err := offline.GetTarget(context.Background(), tc.target); err != nil {
+			t.Errorf("%s: GetTarget unexpectedly returned an error offline: %v", tc.name, err)
+		}
+	}
+}
+
+func TestResolveTargetUncached(t *testing.T) {
+	t.Parallel()
+	testRepo := newDelegatedRepository(t)
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	newInitializedClient(t, testRepo.Dir(), testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+	offline := newInitializedClient(t, testRepo.Dir(), testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+		Offline:       true,
+	})
+	if _, err := offline.ResolveTarget(context.Background(), "top.txt"); err != nil {
+		t.Errorf("ResolveTarget unexpectedly returned an error: %v", err)
+	}
+	// The delegated metadata was never retrieved online.
+	if _, err := offline.ResolveTarget(context.Background(), "teams/a/a.txt"); err == nil {
+		t.Error("ResolveTarget returned, expected error for uncached delegated metadata")
+	}
+}
+
+func TestGetDelegatedTrustedRoot(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	testRepo.Delegate("targets", "roots", []string{TrustedRootTarget}, false)
+	testRepo.AddTargetToRole("roots", TrustedRootTarget, tuftest.NewTrustedRootJSON(t), nil)
+	testRepo.Publish()
+
+	client := newInitializedClient(t, testRepo.Dir(), testRepo.Root(), &ClientOptions{CacheType: Memory})
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	target, err := client.ResolveTarget(context.Background(), TrustedRootTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Role != "roots" {
+		t.Errorf("expected %s signed by roots, got %s", TrustedRootTarget, target.Role)
+	}
+}
+
+func TestResolveTargetMirrors(t *testing.T) {
+	t.Parallel()
+	testRepo := newDelegatedRepository(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	// The delegated metadata is downloaded from the mirror while the
+	// remote is down.
+	c, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Initialize(context.Background(), &RepositoryOptions{
+		Name:    "sigstore-staging",
+		Remote:  down.URL,
+		Mirrors: []string{fmt.Sprintf("file://%s/repository", testRepo.Dir())},
+		Root:    testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	target, err := c.ResolveTarget(context.Background(), "teams/a/project-p.txt")
+	if err != nil {
+		t.Fatalf("ResolveTarget unexpectedly returned an error: %v", err)
+	}
+	if want := []string{"targets", "team-a", "project"}; !reflect.DeepEqual(target.Delegations, want) {
+		t.Errorf("expected delegations %v, got %v", want, target.Delegations)
+	}
+	if b, err := c.GetTarget(context.Background(), "teams/b/b.txt"); err != nil || string(b) != "b" {
+		t.Errorf("GetTarget returned %q, %v", b, err)
+	}
+
+	// An unknown target is not a failure of the mirror.
+	var notFound client.ErrNotFound
+	if _, err := c.ResolveTarget(context.Background(), "teams/a/unknown.txt"); !errors.As(err, &notFound) {
+		t.Errorf("ResolveTarget returned %v, expected not found error", err)
+	}
+	for _, st := range c.Mirrors() {
+		if st.URL != down.URL && st.Failures != 0 {
+			t.Errorf("%s: expected no failures, got %d: %s", st.URL, st.Failures, st.LastError)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+const (
+	// diskStoreTempPrefix prefixes the temporary files of atomic writes.
+	diskStoreTempPrefix = ".tmp-"
+	// diskStoreLockFile is the file locked during updates.
+	diskStoreLockFile = ".lock"
+)
+
+// sharedStore is a local store that other processes may update concurrently,
+// such as a disk cache shared by several jobs.
+type sharedStore interface {
+	client.LocalStore
+	// lock takes an exclusive advisory lock on the store, held until unlock
+	// is called.
+	lock() (unlock func() error, err error)
+	// repair removes the metadata that is not well-formed, so that it is
+	// downloaded again.
+	repair() error
+	// clear removes all the metadata of the store, and the files cached next
+	// to it.
+	clear() error
+}
+
+// diskStore is a client.LocalStore persisting metadata as JSON files in a
+// directory. Files are written atomically, so that readers in other processes
+// never observe partial writes.
+type diskStore struct {
+	dir string
+}
+
+var _ sharedStore = (*diskStore)(nil)
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (d *diskStore) GetMeta() (map[string]json.RawMessage, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+	meta := make(map[string]json.RawMessage)
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, diskStoreTempPrefix) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(d.dir, name))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Deleted by another process.
+				continue
+			}
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		meta[name] = b
+	}
+	return meta, nil
+}
+
+// SetMeta atomically replaces the file of a metadata.
+func (d *diskStore) SetMeta(name string, meta json.RawMessage) error {
+	if err := checkMetaName(name); err != nil {
+		return err
+	}
+	return writeFileAtomic(d.dir, name, meta)
+}
+
+func (d *diskStore) DeleteMeta(name string) error {
+	if err := checkMetaName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(d.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("deleting %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *diskStore) Close() error {
+	return nil
+}
+
+func (d *diskStore) lock() (func() error, error) {
+	f, err := os.OpenFile(filepath.Join(d.dir, diskStoreLockFile), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening cache lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking cache: %w", err)
+	}
+	return func() error {
+		defer f.Close()
+		return unlockFile(f)
+	}, nil
+}
+
+func (d *diskStore) repair() error {
+	meta, err := d.GetMeta()
+	if err != nil {
+		return err
+	}
+	for name, b := range meta {
+		if json.Unmarshal(b, &data.Signed{}) == nil {
+			continue
+		}
+		if err := d.DeleteMeta(name); err != nil {
+			return fmt.Errorf("removing corrupted %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (d *diskStore) clear() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return fmt.Errorf("reading cache directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.Name() == diskStoreLockFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(d.dir, e.Name())); err != nil {
+			return fmt.Errorf("clearing cache directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkMetaName rejects metadata names that are not plain JSON file names.
+func checkMetaName(name string) error {
+	if filepath.Ext(name) != ".json" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid metadata name %q", name)
+	}
+	return nil
+}
+
+// writeFileAtomic atomically replaces a file in dir: it is written to a
+// temporary file, synced and renamed.
+func writeFileAtomic(dir, name string, b []byte) error {
+	f, err := os.CreateTemp(dir, diskStoreTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	// The temporary file is removed unless it was renamed.
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// diskFileStore is a fileStore persisting files in the subdirectories of a
+// disk store, which it ignores. Files are written atomically, as metadata.
+type diskFileStore struct {
+	dir string
+}
+
+var _ fileStore = (*diskFileStore)(nil)
+
+func (d *diskFileStore) readFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(d.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return b, nil
+}
+
+func (d *diskFileStore) writeFile(name string, b []byte) error {
+	dir, base := path.Split(name)
+	dir = filepath.Join(d.dir, filepath.FromSlash(dir))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return writeFileAtomic(dir, base, b)
+}
@@ -0,0 +1,220 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestDiskStore(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "cache")
+	d, err := newDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetMeta("root.json", []byte(`{"signed": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetMeta("root.json", []byte(`{"signed": {"version": 2}}`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"root", "../root.json", ".lock.json"} {
+		if err := d.SetMeta(name, []byte(`{}`)); err == nil {
+			t.Errorf("SetMeta(%q) returned, expected error", name)
+		}
+	}
+	// Leftovers of interrupted writes are ignored.
+	if err := os.WriteFile(filepath.Join(dir, diskStoreTempPrefix+"123.json"), []byte(`{"sig`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 1 || string(meta["root.json"]) != `{"signed": {"version": 2}}` {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected the metadata and one leftover temporary file, got %d files", len(entries))
+	}
+
+	if err := d.DeleteMeta("root.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteMeta("root.json"); err != nil {
+		t.Errorf("DeleteMeta unexpectedly returned an error for missing metadata: %v", err)
+	}
+}
+
+func TestDiskFileStore(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "cache")
+	d, err := newDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := &diskFileStore{dir: dir}
+	if _, err := files.readFile(targetFileName("trusted_root.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("readFile returned %v, expected %v", err, fs.ErrNotExist)
+	}
+	for _, name := range []string{targetFileName("trusted_root.json"), rootFileName(1)} {
+		if err := files.writeFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := files.readFile(name); err != nil || string(b) != name {
+			t.Errorf("readFile(%q) returned %q, %v", name, b, err)
+		}
+	}
+
+	// The files are not metadata.
+	meta, err := d.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 0 {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+}
+
+func TestDiskStoreLock(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "cache")
+	d1, err := newDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := newDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := d1.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		unlock2, err := d2.lock()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		unlock2()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lock acquired while held by another store")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired after release")
+	}
+}
+
+func TestInitializeRepairsCorruptedCache(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncate every cached file, as an interrupted writer would.
+	repoDir := filepath.Join(cacheLocation, "sigstore-staging")
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, e.Name()), []byte(`{"sig`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client = newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error after repair: %v", err)
+	}
+}
+
+func TestConcurrentInitializeSharedCache(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	rootJSON := testRepo.Root()
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Disk, CacheLocation: cacheLocation})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := client.Initialize(context.Background(), &RepositoryOptions{
+				Name:   "sigstore-staging",
+				Remote: fmt.Sprintf("file://%s/repository", td),
+				Root:   rootJSON,
+			}); err != nil {
+				errs <- err
+				return
+			}
+			_, err = client.GetTrustedRoot(context.Background())
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ExpiryStatus is the state of metadata with regard to its expiry.
+type ExpiryStatus string
+
+const (
+	// ExpiryStatusValid is metadata that does not expire within the warning
+	// threshold.
+	ExpiryStatusValid ExpiryStatus = "valid"
+	// ExpiryStatusExpiresSoon is metadata that expires within the warning
+	// threshold. It usually means that the repository stalled, e.g. that
+	// the timestamp is no longer signed on schedule.
+	ExpiryStatusExpiresSoon ExpiryStatus = "expires_soon"
+	// ExpiryStatusExpired is expired metadata.
+	ExpiryStatusExpired ExpiryStatus = "expired"
+)
+
+// RoleStatus is the version and expiry of the trusted metadata of a role.
+type RoleStatus struct {
+	Role    string       `json:"role"`
+	Version int64        `json:"version"`
+	Expires time.Time    `json:"expires"`
+	Status  ExpiryStatus `json:"status"`
+}
+
+// statusRoles are the roles reported by MetadataStatus, in order.
+var statusRoles = []string{"root", "targets", "snapshot", "timestamp"}
+
+// MetadataStatus returns the version and expiry of the local trusted
+// metadata of the top-level roles: root, targets, snapshot and timestamp.
+// Metadata expiring within ClientOptions.ExpiryWarningThreshold is reported
+// as ExpiryStatusExpiresSoon. No network call is made: call Refresh first to
+// report the state of the remote.
+func (s *SigstoreTufClient) MetadataStatus() ([]RoleStatus, error) {
+	s.updateMu.RLock()
+	defer s.updateMu.RUnlock()
+	if !s.initialized {
+		return nil, errors.New("sigstore TUF client must be initialized before usage")
+	}
+	return s.metadataStatus(time.Now())
+}
+
+func (s *SigstoreTufClient) metadataStatus(now time.Time) ([]RoleStatus, error) {
+	threshold := s.opts.ExpiryWarningThreshold
+	if threshold == 0 {
+		threshold = DefaultExpiryWarningThreshold
+	}
+	meta, err := s.local.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("reading local metadata: %w", err)
+	}
+	statuses := make([]RoleStatus, 0, len(statusRoles))
+	for _, role := range statusRoles {
+		b, ok := meta[role+".json"]
+		if !ok {
+			return nil, fmt.Errorf("no local %s.json", role)
+		}
+		var signed struct {
+			Signed struct {
+				Version int64     `json:"version"`
+				Expires time.Time `json:"expires"`
+			} `json:"signed"`
+		}
+		if err := json.Unmarshal(b, &signed); err != nil {
+			return nil, fmt.Errorf("parsing local %s.json: %w", role, err)
+		}
+		st := RoleStatus{
+			Role:    role,
+			Version: signed.Signed.Version,
+			Expires: signed.Signed.Expires,
+			Status:  ExpiryStatusValid,
+		}
+		switch {
+		case !now.Before(st.Expires):
+			st.Status = ExpiryStatusExpired
+		case now.Add(threshold).After(st.Expires):
+			st.Status = ExpiryStatusExpiresSoon
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,107 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestMetadataStatus(t *testing.T) {
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	threshold := time.Hour
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+		CacheType:              Memory,
+		ExpiryWarningThreshold: threshold,
+	})
+
+	uninitialized, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uninitialized.MetadataStatus(); err == nil {
+		t.Error("MetadataStatus returned, expected error for uninitialized client")
+	}
+
+	statuses, err := client.MetadataStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(statusRoles) {
+		t.Fatalf("expected %d roles, got %d", len(statusRoles), len(statuses))
+	}
+	for i, st := range statuses {
+		if st.Role != statusRoles[i] || st.Version != 1 || st.Status != ExpiryStatusValid {
+			t.Errorf("unexpected status %+v", st)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		offset time.Duration
+		want   ExpiryStatus
+	}{
+		{
+			name:   "before the warning threshold",
+			offset: -threshold - time.Minute,
+			want:   ExpiryStatusValid,
+		},
+		{
+			name:   "within the warning threshold",
+			offset: -threshold / 2,
+			want:   ExpiryStatusExpiresSoon,
+		},
+		{
+			name:   "expired",
+			offset: time.Second,
+			want:   ExpiryStatusExpired,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			for i, role := range statuses {
+				got, err := client.metadataStatus(role.Expires.Add(tc.offset))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got[i].Status != tc.want {
+					t.Errorf("%s: expected status %s, got %s", role.Role, tc.want, got[i].Status)
+				}
+			}
+		})
+	}
+
+	// The status reflects the metadata after a refresh.
+	testRepo.Publish()
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err = client.MetadataStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := statuses[len(statuses)-1]; ts.Role != "timestamp" || ts.Version != 2 {
+		t.Errorf("unexpected timestamp status after refresh %+v", ts)
+	}
+}
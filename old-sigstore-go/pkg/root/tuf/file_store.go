@@ -0,0 +1,55 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+const (
+	// targetsDir and rootsDir are the directories of the cached targets and
+	// of the previous versions of root.json in a file store.
+	targetsDir = "targets"
+	rootsDir   = "roots"
+)
+
+// fileStore holds the files a repository caches next to its metadata, which
+// the TUF client must not load as metadata: the verified targets, and the
+// root.json versions walked by updates. Names are slash-separated paths.
+type fileStore interface {
+	// readFile returns the content of a file, or an error wrapping
+	// fs.ErrNotExist if there is none.
+	readFile(name string) ([]byte, error)
+	writeFile(name string, b []byte) error
+}
+
+// targetFileName returns the name of a cached target in a file store. Target
+// names may contain path separators and dot segments, so targets are stored
+// under the digest of their normalized name.
+func targetFileName(name string) string {
+	digest := sha256.Sum256([]byte(util.NormalizeTarget(name)))
+	return path.Join(targetsDir, hex.EncodeToString(digest[:]))
+}
+
+// rootFileName returns the name of a version of root.json in a file store.
+func rootFileName(version int64) string {
+	return path.Join(rootsDir, fmt.Sprintf("%d.root.json", version))
+}
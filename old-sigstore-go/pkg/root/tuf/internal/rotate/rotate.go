@@ -0,0 +1,141 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rotate rotates the keys of the top-level roles of go-tuf
+// repositories, for the publish and tuftest packages.
+package rotate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+	"github.com/theupdateframework/go-tuf/sign"
+)
+
+// Key replaces the key of a top-level role of repo, stored in store, with a
+// new one, and stages root.json with an incremented version. When the root
+// key is rotated, the new root.json is signed by both the previous and the
+// new key, so that clients trusting the previous one accept it, and the
+// repository is loaded again from store. When the targets key is rotated, the
+// targets metadata is signed again with the new key. expires returns the
+// expiration time of the metadata of a role signed now. Key returns the
+// repository to use from then on.
+func Key(store tuf.LocalStore, repo *tuf.Repo, role string, expires func(role string) time.Time) (*tuf.Repo, error) {
+	meta, err := store.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	oldRoot, err := unmarshalRoot(meta["root.json"])
+	if err != nil {
+		return nil, err
+	}
+	oldRole, ok := oldRoot.Roles[role]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a top-level role", role)
+	}
+	var oldSigners []keys.Signer
+	if role == "root" {
+		if oldSigners, err = Signers(store, role, oldRole.KeyIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	rootExpires := expires("root")
+	if _, err := repo.GenKeyWithExpires(role, rootExpires); err != nil {
+		return nil, fmt.Errorf("generating %s key: %w", role, err)
+	}
+	for _, id := range oldRole.KeyIDs {
+		// A key is revoked along with all of its IDs.
+		var notFound tuf.ErrKeyNotFound
+		if err := repo.RevokeKeyWithExpires(role, id, rootExpires); err != nil && !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("revoking %s key: %w", role, err)
+		}
+	}
+
+	switch role {
+	case "root":
+		s, err := repo.SignedMeta("root.json")
+		if err != nil {
+			return nil, err
+		}
+		for _, signer := range oldSigners {
+			if err := sign.Sign(s, signer); err != nil {
+				return nil, fmt.Errorf("signing root.json: %w", err)
+			}
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.SetMeta("root.json", b); err != nil {
+			return nil, err
+		}
+		// The go-tuf repository caches the metadata it signed.
+		return tuf.NewRepo(store)
+	case "targets":
+		// Without paths, the top-level targets are signed again. Snapshot
+		// and timestamp are signed again when published.
+		if err := repo.AddTargetsWithExpires(nil, nil, expires("targets")); err != nil {
+			return nil, fmt.Errorf("signing targets.json: %w", err)
+		}
+	}
+	return repo, nil
+}
+
+// Signers returns the signers of a role saved in store with the given key
+// IDs.
+func Signers(store tuf.LocalStore, role string, keyIDs []string) ([]keys.Signer, error) {
+	saved, err := store.GetSigners(role)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s keys: %w", role, err)
+	}
+	ids := make(map[string]bool, len(keyIDs))
+	for _, id := range keyIDs {
+		ids[id] = true
+	}
+	var signers []keys.Signer
+	for _, signer := range saved {
+		for _, id := range signer.PublicData().IDs() {
+			if ids[id] {
+				signers = append(signers, signer)
+				break
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no %s key found", role)
+	}
+	return signers, nil
+}
+
+func unmarshalRoot(b []byte) (*data.Root, error) {
+	if b == nil {
+		return nil, errors.New("repository is not initialized")
+	}
+	s := &data.Signed{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	rootMeta := &data.Root{}
+	if err := json.Unmarshal(s.Signed, rootMeta); err != nil {
+		return nil, err
+	}
+	return rootMeta, nil
+}
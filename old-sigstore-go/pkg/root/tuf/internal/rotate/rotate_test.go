@@ -0,0 +1,89 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rotate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+func TestKey(t *testing.T) {
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		role := role
+		t.Run(role, func(t *testing.T) {
+			t.Parallel()
+			store := tuf.MemoryStore(nil, map[string][]byte{"foo.txt": []byte("foo")})
+			repo, err := tuf.NewRepo(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Init(false); err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range []string{"root", "targets", "snapshot", "timestamp"} {
+				if _, err := repo.GenKey(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			oldKeyIDs := rootKeyIDs(t, store, role)
+
+			if repo, err = Key(store, repo, role, data.DefaultExpires); err != nil {
+				t.Fatalf("Key unexpectedly returned an error: %v", err)
+			}
+			newKeyIDs := rootKeyIDs(t, store, role)
+			if len(newKeyIDs) == 0 || reflect.DeepEqual(oldKeyIDs, newKeyIDs) {
+				t.Errorf("expected new %s keys, got %v", role, newKeyIDs)
+			}
+			if _, err := Signers(store, role, newKeyIDs); err != nil {
+				t.Errorf("Signers unexpectedly returned an error: %v", err)
+			}
+			if err := repo.AddTarget("foo.txt", nil); err != nil {
+				t.Errorf("AddTarget unexpectedly returned an error after rotation: %v", err)
+			}
+		})
+	}
+
+	t.Run("delegated role", func(t *testing.T) {
+		t.Parallel()
+		store := tuf.MemoryStore(nil, nil)
+		repo, err := tuf.NewRepo(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GenKey("root"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Key(store, repo, "team", data.DefaultExpires); err == nil {
+			t.Error("Key returned, expected error for a role that is not top-level")
+		}
+	})
+}
+
+func rootKeyIDs(t *testing.T, store tuf.LocalStore, role string) []string {
+	t.Helper()
+	meta, err := store.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := unmarshalRoot(meta["root.json"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root.Roles[role].KeyIDs
+}
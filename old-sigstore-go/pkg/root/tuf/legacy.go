@@ -0,0 +1,335 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	prototrustroot "github.com/sigstore/protobuf-specs/gen/pb-go/trustroot/v1"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/theupdateframework/go-tuf/data"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Usages of legacy targets, recorded in their "sigstore" custom metadata.
+const (
+	legacyUsageFulcio = "Fulcio"
+	legacyUsageRekor  = "Rekor"
+	legacyUsageCTFE   = "CTFE"
+	legacyUsageTSA    = "TSA"
+)
+
+// Statuses of legacy targets. Expired targets may only verify signatures
+// made in the past.
+const (
+	legacyStatusActive  = "Active"
+	legacyStatusExpired = "Expired"
+)
+
+// legacyCustomMetadata is the custom metadata of a legacy target.
+type legacyCustomMetadata struct {
+	Sigstore *struct {
+		Usage  string `json:"usage"`
+		Status string `json:"status"`
+		URI    string `json:"uri"`
+	} `json:"sigstore"`
+}
+
+// legacyTarget is a trust anchor published as an individual target, such as
+// fulcio.crt.pem or rekor.pub, by repositories predating trusted_root.json.
+type legacyTarget struct {
+	name   string
+	usage  string
+	status string
+	uri    string
+	data   []byte
+}
+
+// legacyTargetNames returns the names of the targets carrying "sigstore"
+// custom metadata with a known usage, in a stable order.
+func legacyTargetNames(targets data.TargetFiles) []string {
+	var names []string
+	for name, meta := range targets {
+		if _, err := parseLegacyCustomMetadata(meta.Custom); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseLegacyCustomMetadata(custom *json.RawMessage) (*legacyCustomMetadata, error) {
+	if custom == nil {
+		return nil, errors.New("missing custom metadata")
+	}
+	m := &legacyCustomMetadata{}
+	if err := json.Unmarshal(*custom, m); err != nil {
+		return nil, fmt.Errorf("parsing custom metadata: %w", err)
+	}
+	if m.Sigstore == nil {
+		return nil, errors.New("missing sigstore custom metadata")
+	}
+	switch m.Sigstore.Usage {
+	case legacyUsageFulcio, legacyUsageRekor, legacyUsageCTFE, legacyUsageTSA:
+	default:
+		return nil, fmt.Errorf("unknown usage %q", m.Sigstore.Usage)
+	}
+	return m, nil
+}
+
+// legacyTrustedRoot assembles a trusted root from legacy targets. Targets with
+// an Expired status are trusted until now. Public keys carry no validity
+// period, so Active keys are trusted at any time, and certificate authorities
+// are trusted from the issuance of their most recent certificate.
+func legacyTrustedRoot(targets []legacyTarget, now time.Time) (*prototrustroot.TrustedRoot, error) {
+	tr := &prototrustroot.TrustedRoot{MediaType: root.TrustedRootMediaType01}
+	var caCerts, tsaCerts []legacyCertificate
+	for _, target := range targets {
+		if target.status != legacyStatusActive && target.status != legacyStatusExpired {
+			return nil, fmt.Errorf("target %s: unknown status %q", target.name, target.status)
+		}
+		switch target.usage {
+		case legacyUsageRekor, legacyUsageCTFE:
+			l, err := legacyLog(target, now)
+			if err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.name, err)
+			}
+			if target.usage == legacyUsageRekor {
+				tr.Tlogs = append(tr.Tlogs, l)
+			} else {
+				tr.Ctlogs = append(tr.Ctlogs, l)
+			}
+		case legacyUsageFulcio, legacyUsageTSA:
+			certs, err := cryptoutils.UnmarshalCertificatesFromPEM(target.data)
+			if err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.name, err)
+			}
+			if len(certs) == 0 {
+				return nil, fmt.Errorf("target %s: no certificates", target.name)
+			}
+			for _, cert := range certs {
+				c := legacyCertificate{cert: cert, target: target}
+				if target.usage == legacyUsageFulcio {
+					caCerts = append(caCerts, c)
+				} else {
+					tsaCerts = append(tsaCerts, c)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("target %s: unknown usage %q", target.name, target.usage)
+		}
+	}
+	var err error
+	if tr.CertificateAuthorities, err = legacyAuthorities(caCerts, now); err != nil {
+		return nil, fmt.Errorf("certificate authorities: %w", err)
+	}
+	if tr.TimestampAuthorities, err = legacyAuthorities(tsaCerts, now); err != nil {
+		return nil, fmt.Errorf("timestamp authorities: %w", err)
+	}
+	if len(tr.Tlogs) == 0 && len(tr.Ctlogs) == 0 && len(tr.CertificateAuthorities) == 0 &&
+		len(tr.TimestampAuthorities) == 0 {
+		return nil, errors.New("no legacy trust anchors")
+	}
+	return tr, nil
+}
+
+func legacyLog(target legacyTarget, now time.Time) (*prototrustroot.TransparencyLogInstance, error) {
+	pub, err := cryptoutils.UnmarshalPEMToPublicKey(target.data)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var keyDetails protocommon.PublicKeyDetails
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		// The trusted root format only describes ECDSA keys on P-256.
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+		keyDetails = protocommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256
+	case *rsa.PublicKey:
+		keyDetails = protocommon.PublicKeyDetails_PKIX_RSA_PKCS1V5
+	case ed25519.PublicKey:
+		keyDetails = protocommon.PublicKeyDetails_PKIX_ED25519
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	logID, err := tlog.ComputeLogID(pub)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := hex.DecodeString(logID)
+	if err != nil {
+		return nil, err
+	}
+	return &prototrustroot.TransparencyLogInstance{
+		BaseUrl:       target.uri,
+		HashAlgorithm: protocommon.HashAlgorithm_SHA2_256,
+		PublicKey: &protocommon.PublicKey{
+			RawBytes:   der,
+			KeyDetails: keyDetails,
+			ValidFor:   legacyTimeRange(time.Time{}, target.status, now),
+		},
+		LogId: &protocommon.LogId{KeyId: keyID},
+	}, nil
+}
+
+// legacyCertificate is a certificate and the target it was published in.
+type legacyCertificate struct {
+	cert   *x509.Certificate
+	target legacyTarget
+}
+
+// legacyAuthorities groups certificates into chains, since legacy
+// repositories publish roots, intermediates and leaves in any number of
+// targets. Every self-signed certificate starts a chain, and every other
+// certificate extends the chains of its issuer. An authority takes the URI of
+// the most specific certificate in its chain, and is expired if any of them
+// is.
+func legacyAuthorities(certs []legacyCertificate, now time.Time) ([]*prototrustroot.CertificateAuthority, error) {
+	var chains [][]legacyCertificate
+	var pending []legacyCertificate
+	for i, c := range certs {
+		duplicate := false
+		for _, prev := range certs[:i] {
+			if bytes.Equal(prev.cert.Raw, c.cert.Raw) {
+				duplicate = true
+				break
+			}
+		}
+		switch {
+		case duplicate:
+		case c.cert.CheckSignatureFrom(c.cert) == nil:
+			chains = append(chains, []legacyCertificate{c})
+		default:
+			pending = append(pending, c)
+		}
+	}
+	// Extend chains until no pending certificate has an issuer in a chain.
+	for len(pending) > 0 {
+		var remaining []legacyCertificate
+		for _, c := range pending {
+			var found bool
+			if chains, found = extendChains(chains, c); !found {
+				remaining = append(remaining, c)
+			}
+		}
+		if len(remaining) == len(pending) {
+			break
+		}
+		pending = remaining
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("no issuer for certificate %s in target %s",
+			pending[0].cert.Subject, pending[0].target.name)
+	}
+
+	var cas []*prototrustroot.CertificateAuthority
+	for _, chain := range chains {
+		var start time.Time
+		status := legacyStatusActive
+		pbCerts := make([]*protocommon.X509Certificate, len(chain))
+		for i, c := range chain {
+			// Chains are ordered from the leaf towards the root.
+			pbCerts[len(chain)-1-i] = &protocommon.X509Certificate{RawBytes: c.cert.Raw}
+			if c.cert.NotBefore.After(start) {
+				start = c.cert.NotBefore
+			}
+			if c.target.status == legacyStatusExpired {
+				status = legacyStatusExpired
+			}
+		}
+		cas = append(cas, &prototrustroot.CertificateAuthority{
+			Subject:   &protocommon.DistinguishedName{CommonName: chain[0].cert.Subject.CommonName},
+			Uri:       chain[len(chain)-1].target.uri,
+			CertChain: &protocommon.X509CertificateChain{Certificates: pbCerts},
+			ValidFor:  legacyTimeRange(start, status, now),
+		})
+	}
+	return cas, nil
+}
+
+// extendChains appends c to every chain containing its issuer. When the
+// issuer already issued another certificate of the chain, a new chain ending
+// in c is forked instead. It reports whether an issuer was found.
+func extendChains(chains [][]legacyCertificate, c legacyCertificate) ([][]legacyCertificate, bool) {
+	found := false
+	extended := make([][]legacyCertificate, 0, len(chains))
+	for _, chain := range chains {
+		extended = append(extended, chain)
+		for j, issuer := range chain {
+			if c.cert.CheckSignatureFrom(issuer.cert) != nil {
+				continue
+			}
+			found = true
+			newChain := append(append([]legacyCertificate{}, chain[:j+1]...), c)
+			if j == len(chain)-1 {
+				extended[len(extended)-1] = newChain
+			} else if !containsChain(extended, newChain) {
+				extended = append(extended, newChain)
+			}
+			break
+		}
+	}
+	return extended, found
+}
+
+func containsChain(chains [][]legacyCertificate, chain []legacyCertificate) bool {
+	for _, other := range chains {
+		if len(other) != len(chain) {
+			continue
+		}
+		same := true
+		for i := range other {
+			if !bytes.Equal(other[i].cert.Raw, chain[i].cert.Raw) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+func legacyTimeRange(start time.Time, status string, now time.Time) *protocommon.TimeRange {
+	tr := &protocommon.TimeRange{}
+	if !start.IsZero() {
+		tr.Start = timestamppb.New(start)
+	}
+	if status == legacyStatusExpired {
+		tr.End = timestamppb.New(now)
+	}
+	return tr
+}
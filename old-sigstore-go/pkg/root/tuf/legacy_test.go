@@ -0,0 +1,243 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// newLegacyCert creates a certificate for name issued by parent, or a
+// self-signed one when parent is nil.
+func newLegacyCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2032, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, priv
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, priv.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func pemCerts(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+	b, err := cryptoutils.MarshalCertificatesToPEM(certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newLegacyPublicKey(t *testing.T) []byte {
+	t.Helper()
+	return newLegacyCurveKey(t, elliptic.P256())
+}
+
+func newLegacyCurveKey(t *testing.T, curve elliptic.Curve) []byte {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestLegacyTrustedRoot(t *testing.T) {
+	fulcioRoot, fulcioRootKey := newLegacyCert(t, "fulcio", true, nil, nil)
+	fulcioIntermediate, _ := newLegacyCert(t, "fulcio-intermediate", true, fulcioRoot, fulcioRootKey)
+	oldFulcioRoot, _ := newLegacyCert(t, "fulcio-old", true, nil, nil)
+	tsaRoot, tsaRootKey := newLegacyCert(t, "tsa", true, nil, nil)
+	tsaLeaf, _ := newLegacyCert(t, "tsa-leaf", false, tsaRoot, tsaRootKey)
+	unpublishedRoot, unpublishedRootKey := newLegacyCert(t, "unpublished", true, nil, nil)
+	orphan, _ := newLegacyCert(t, "orphan", false, unpublishedRoot, unpublishedRootKey)
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rekor := legacyTarget{name: "rekor.pub", usage: legacyUsageRekor, status: legacyStatusActive,
+		uri: "https://rekor.sigstore.dev", data: newLegacyPublicKey(t)}
+	ctfe := legacyTarget{name: "ctfe.pub", usage: legacyUsageCTFE, status: legacyStatusExpired,
+		uri: "https://ctfe.sigstore.dev/test", data: newLegacyPublicKey(t)}
+	fulcio := legacyTarget{name: "fulcio_v1.crt.pem", usage: legacyUsageFulcio, status: legacyStatusActive,
+		uri: "https://fulcio.sigstore.dev", data: pemCerts(t, fulcioRoot)}
+	intermediate := legacyTarget{name: "fulcio_intermediate_v1.crt.pem", usage: legacyUsageFulcio,
+		status: legacyStatusActive, uri: "https://fulcio.sigstore.dev", data: pemCerts(t, fulcioIntermediate)}
+	oldFulcio := legacyTarget{name: "fulcio.crt.pem", usage: legacyUsageFulcio, status: legacyStatusExpired,
+		uri: "https://fulcio.sigstore.dev", data: pemCerts(t, oldFulcioRoot)}
+	tsa := legacyTarget{name: "tsa.certchain.pem", usage: legacyUsageTSA, status: legacyStatusActive,
+		uri: "https://tsa.sigstore.dev", data: pemCerts(t, tsaLeaf, tsaRoot)}
+
+	testCases := []struct {
+		name    string
+		targets []legacyTarget
+		wantErr bool
+	}{
+		{
+			name:    "all usages",
+			targets: []legacyTarget{rekor, ctfe, intermediate, fulcio, oldFulcio, tsa},
+		},
+		{
+			name: "unknown status",
+			targets: []legacyTarget{{name: "rekor.pub", usage: legacyUsageRekor, status: "Revoked",
+				data: rekor.data}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid public key",
+			targets: []legacyTarget{{name: "rekor.pub", usage: legacyUsageRekor, status: legacyStatusActive}},
+			wantErr: true,
+		},
+		{
+			name: "P-384 log key",
+			targets: []legacyTarget{{name: "rekor.pub", usage: legacyUsageRekor, status: legacyStatusActive,
+				data: newLegacyCurveKey(t, elliptic.P384())}},
+			wantErr: true,
+		},
+		{
+			name: "certificate without issuer",
+			targets: []legacyTarget{tsa, {name: "orphan.pem", usage: legacyUsageTSA,
+				status: legacyStatusActive, data: pemCerts(t, orphan)}},
+			wantErr: true,
+		},
+		{
+			name:    "no targets",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			pb, err := legacyTrustedRoot(tc.targets, now)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("legacyTrustedRoot unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("legacyTrustedRoot returned, expected error")
+			}
+			tr, err := root.NewTrustedRootFromProtobuf(pb)
+			if err != nil {
+				t.Fatalf("assembled trusted root is invalid: %v", err)
+			}
+			if len(tr.TransparencyLogs()) != 1 || len(tr.CTLogs()) != 1 {
+				t.Fatalf("expected 1 transparency log and 1 CT log, got %d and %d",
+					len(tr.TransparencyLogs()), len(tr.CTLogs()))
+			}
+			for _, l := range tr.CTLogs() {
+				if !l.ValidityPeriodEnd.Equal(now) {
+					t.Errorf("expected expired CT log to end at %s, got %s", now, l.ValidityPeriodEnd)
+				}
+			}
+			cas := tr.CertificateAuthorities()
+			if len(cas) != 2 {
+				t.Fatalf("expected 2 certificate authorities, got %d", len(cas))
+			}
+			for _, ca := range cas {
+				switch ca.Root.Subject.CommonName {
+				case "fulcio":
+					if len(ca.Intermediates) != 1 || !ca.ValidityPeriodEnd.IsZero() {
+						t.Errorf("unexpected active certificate authority %+v", ca)
+					}
+				case "fulcio-old":
+					if !ca.ValidityPeriodEnd.Equal(now) {
+						t.Errorf("expected expired certificate authority to end at %s", now)
+					}
+				default:
+					t.Errorf("unexpected certificate authority %s", ca.Root.Subject.CommonName)
+				}
+			}
+			tsas := tr.TimestampAuthorities()
+			if len(tsas) != 1 || tsas[0].Leaf == nil || tsas[0].URI != "https://tsa.sigstore.dev" {
+				t.Errorf("unexpected timestamp authorities %+v", tsas)
+			}
+		})
+	}
+}
+
+func TestGetLegacyTrustedRoot(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	fulcioRoot, _ := newLegacyCert(t, "fulcio", true, nil, nil)
+	testRepo.AddTarget("rekor.pub", newLegacyPublicKey(t),
+		[]byte(`{"sigstore": {"usage": "Rekor", "status": "Active", "uri": "https://rekor.sigstore.dev"}}`))
+	testRepo.AddTarget("fulcio.crt.pem", pemCerts(t, fulcioRoot),
+		[]byte(`{"sigstore": {"usage": "Fulcio", "status": "Active", "uri": "https://fulcio.sigstore.dev"}}`))
+	testRepo.AddTarget("artifact.txt", []byte("unrelated"), nil)
+	testRepo.Publish()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: fmt.Sprintf("file://%s/repository", td),
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := client.GetTrustedRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if len(tr.TransparencyLogs()) != 1 {
+		t.Errorf("expected 1 transparency log, got %d", len(tr.TransparencyLogs()))
+	}
+	cas := tr.CertificateAuthorities()
+	if len(cas) != 1 || cas[0].URI != "https://fulcio.sigstore.dev" {
+		t.Errorf("unexpected certificate authorities %+v", cas)
+	}
+}
@@ -0,0 +1,108 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// memoryStore is a client.LocalStore holding metadata in memory. Unlike
+// client.MemoryLocalStore, it is safe for concurrent use, since delegated
+// metadata is downloaded while other goroutines read the metadata.
+type memoryStore struct {
+	mu   sync.RWMutex
+	meta map[string]json.RawMessage
+}
+
+var _ client.LocalStore = (*memoryStore)(nil)
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{meta: make(map[string]json.RawMessage)}
+}
+
+// GetMeta returns a copy of the metadata, which later updates do not modify.
+func (m *memoryStore) GetMeta() (map[string]json.RawMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	meta := make(map[string]json.RawMessage, len(m.meta))
+	for name, b := range m.meta {
+		meta[name] = b
+	}
+	return meta, nil
+}
+
+func (m *memoryStore) SetMeta(name string, meta json.RawMessage) error {
+	// The caller may reuse its buffer.
+	b := append(json.RawMessage(nil), meta...)
+	m.mu.Lock()
+	m.meta[name] = b
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryStore) DeleteMeta(name string) error {
+	m.mu.Lock()
+	delete(m.meta, name)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+// replace replaces all the metadata at once.
+func (m *memoryStore) replace(meta map[string]json.RawMessage) {
+	m.mu.Lock()
+	m.meta = meta
+	m.mu.Unlock()
+}
+
+// memoryFileStore is a fileStore holding files in memory.
+type memoryFileStore struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+var _ fileStore = (*memoryFileStore)(nil)
+
+func newMemoryFileStore() *memoryFileStore {
+	return &memoryFileStore{files: make(map[string][]byte)}
+}
+
+func (m *memoryFileStore) readFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.files[name]
+	if !ok {
+		return nil, fmt.Errorf("reading %s: %w", name, fs.ErrNotExist)
+	}
+	return b, nil
+}
+
+func (m *memoryFileStore) writeFile(name string, b []byte) error {
+	// The caller may reuse its buffer.
+	b = append([]byte(nil), b...)
+	m.mu.Lock()
+	m.files[name] = b
+	m.mu.Unlock()
+	return nil
+}
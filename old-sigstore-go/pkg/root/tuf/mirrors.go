@@ -0,0 +1,167 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// MirrorStatus is the health of a remote of the repository, as observed by
+// the client.
+type MirrorStatus struct {
+	// URL is the location of the remote.
+	URL string `json:"url"`
+	// Failures is the number of consecutive operations that failed with the
+	// remote, reset by a successful one.
+	Failures int `json:"failures"`
+	// LastError is the error of the last failed operation, if any.
+	LastError string `json:"last_error,omitempty"`
+	// LastFailure is the time of the last failed operation, if any.
+	LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+// mirrorHealth tracks the failures of the remotes of a repository.
+type mirrorHealth struct {
+	mu     sync.Mutex
+	status map[string]*MirrorStatus
+}
+
+// get returns the status of a remote. The caller must hold mu.
+func (h *mirrorHealth) get(remote string) *MirrorStatus {
+	if h.status == nil {
+		h.status = make(map[string]*MirrorStatus)
+	}
+	st, ok := h.status[remote]
+	if !ok {
+		st = &MirrorStatus{URL: remote}
+		h.status[remote] = st
+	}
+	return st
+}
+
+// order returns the remotes from the healthiest, with the fewest consecutive
+// failures, preserving the configured order otherwise.
+func (h *mirrorHealth) order(remotes []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ordered := append([]string(nil), remotes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return h.get(ordered[i]).Failures < h.get(ordered[j]).Failures
+	})
+	return ordered
+}
+
+// record records the outcome of an operation with a remote.
+func (h *mirrorHealth) record(remote string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.get(remote)
+	if err == nil {
+		st.Failures = 0
+		return
+	}
+	st.Failures++
+	st.LastError = err.Error()
+	st.LastFailure = time.Now()
+}
+
+// remotes returns the locations of the repository, the remote first.
+func remotes(opts *RepositoryOptions) []string {
+	return append([]string{opts.Remote}, opts.Mirrors...)
+}
+
+// mirrorOptions returns the options to reach remote, one of the locations of
+// the repository of opts. The HTTP headers, which may hold credentials, are
+// only sent to the host of the remote of opts.
+func mirrorOptions(opts *RepositoryOptions, remote string) *RepositoryOptions {
+	mirrorOpts := *opts
+	mirrorOpts.Remote = remote
+	if !sameHost(opts.Remote, remote) {
+		mirrorOpts.HTTP.Headers = nil
+	}
+	return &mirrorOpts
+}
+
+// sameHost reports whether the URLs a and b have the same scheme and host,
+// including the port.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
+
+// Mirrors returns the health of the remote and mirrors of the initialized
+// repository, in configured order.
+func (s *SigstoreTufClient) Mirrors() []MirrorStatus {
+	s.updateMu.RLock()
+	opts := s.repoOpts
+	s.updateMu.RUnlock()
+	if opts == nil {
+		return nil
+	}
+	s.mirrors.mu.Lock()
+	defer s.mirrors.mu.Unlock()
+	statuses := make([]MirrorStatus, 0, len(opts.Mirrors)+1)
+	for _, remote := range remotes(opts) {
+		statuses = append(statuses, *s.mirrors.get(remote))
+	}
+	return statuses
+}
+
+// withMirrors calls fn with a TUF client loading its trusted metadata from
+// local for each remote of the repository in turn, from the healthiest, until
+// it succeeds. Any error, including metadata
+// or targets failing verification, falls back to the next remote, unless ctx
+// is done.
+func (s *SigstoreTufClient) withMirrors(ctx context.Context, local client.LocalStore, opts *RepositoryOptions, fn func(c *client.Client) error) error {
+	ordered := s.mirrors.order(remotes(opts))
+	var err error
+	for _, remote := range ordered {
+		var c *client.Client
+		c, err = s.newClient(ctx, local, mirrorOptions(opts, remote))
+		if err == nil {
+			err = fn(c)
+		}
+		if ctx.Err() != nil {
+			// The remote is not at fault.
+			return err
+		}
+		s.mirrors.record(remote, err)
+		if err == nil {
+			return nil
+		}
+		if len(ordered) > 1 {
+			err = fmt.Errorf("remote %s: %w", remote, err)
+		}
+	}
+	if len(ordered) > 1 {
+		return fmt.Errorf("all %d remotes failed, last error: %w", len(ordered), err)
+	}
+	return err
+}
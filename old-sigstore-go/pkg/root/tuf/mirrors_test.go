@@ -0,0 +1,225 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestMirrors(t *testing.T) {
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	good := fmt.Sprintf("file://%s/repository", td)
+
+	otherRepo := tuftest.NewRepository(t)
+	otherTd := otherRepo.Dir()
+	otherRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	otherRepo.Publish()
+	other := fmt.Sprintf("file://%s/repository", otherTd)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	// Serves the metadata of the repository, and tampered targets.
+	files := http.FileServer(http.Dir(filepath.Join(td, "repository")))
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/targets/") {
+			fmt.Fprint(w, "tampered")
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(tampered.Close)
+
+	testCases := []struct {
+		name         string
+		remote       string
+		mirrors      []string
+		wantErr      bool
+		wantFailures []int
+	}{
+		{
+			name:         "healthy remote",
+			remote:       good,
+			mirrors:      []string{down.URL},
+			wantFailures: []int{0, 0},
+		},
+		{
+			name:         "remote down",
+			remote:       down.URL,
+			mirrors:      []string{good},
+			wantFailures: []int{1, 0},
+		},
+		{
+			name:         "remote serving metadata failing verification",
+			remote:       other,
+			mirrors:      []string{down.URL, good},
+			wantFailures: []int{1, 1, 0},
+		},
+		{
+			name:         "remote serving targets failing verification",
+			remote:       tampered.URL,
+			mirrors:      []string{good},
+			wantFailures: []int{1, 0},
+		},
+		{
+			name:    "all remotes down",
+			remote:  down.URL,
+			mirrors: []string{"https://tuf.invalid", other},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.Initialize(context.Background(), &RepositoryOptions{
+				Name:    "sigstore-staging",
+				Remote:  tc.remote,
+				Mirrors: tc.mirrors,
+				Root:    testRepo.Root(),
+			})
+			if err == nil {
+				_, err = client.GetTrustedRoot(context.Background())
+			}
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("GetTrustedRoot returned, expected error")
+			}
+			statuses := client.Mirrors()
+			if len(statuses) != len(tc.wantFailures) {
+				t.Fatalf("expected %d mirror statuses, got %d", len(tc.wantFailures), len(statuses))
+			}
+			for i, st := range statuses {
+				if st.Failures != tc.wantFailures[i] {
+					t.Errorf("%s: expected %d failures, got %d", st.URL, tc.wantFailures[i], st.Failures)
+				}
+				if st.Failures > 0 && st.LastError == "" {
+					t.Errorf("%s: missing last error", st.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorsOrderedByHealth(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+
+	var requests int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:    "sigstore-staging",
+		Remote:  down.URL,
+		Mirrors: []string{fmt.Sprintf("file://%s/repository", td)},
+		Root:    testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt32(&requests)
+	// The failing remote is tried after the healthy mirror.
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if after := atomic.LoadInt32(&requests); after != before {
+		t.Errorf("failing remote contacted %d times, expected the healthy mirror first", after-before)
+	}
+}
+
+func TestMirrorsHeaders(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+
+	var remoteAuthorized, mirrorAuthorized int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			atomic.AddInt32(&remoteAuthorized, 1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+	files := http.FileServer(http.Dir(filepath.Join(td, "repository")))
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			atomic.AddInt32(&mirrorAuthorized, 1)
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(mirror.Close)
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:    "sigstore-staging",
+		Remote:  down.URL,
+		Mirrors: []string{mirror.URL},
+		Root:    testRepo.Root(),
+		HTTP:    HTTPOptions{Headers: http.Header{"Authorization": []string{"Bearer secret"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&remoteAuthorized) == 0 {
+		t.Error("the remote did not receive the headers")
+	}
+	if n := atomic.LoadInt32(&mirrorAuthorized); n != 0 {
+		t.Errorf("the mirror on another host received the headers %d times", n)
+	}
+}
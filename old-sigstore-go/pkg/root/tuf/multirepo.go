@@ -0,0 +1,266 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+// MapFile is a TAP-4 map.json, which maps targets to the repositories that
+// must agree on them.
+// https://github.com/theupdateframework/taps/blob/master/tap4.md
+type MapFile struct {
+	// Repositories maps repository names to their URLs.
+	Repositories map[string][]string `json:"repositories"`
+	// Mapping is the ordered list of mappings.
+	Mapping []Mapping `json:"mapping"`
+}
+
+// Mapping assigns the targets matching any of its path patterns to a set of
+// repositories.
+type Mapping struct {
+	// Paths are path.Match patterns of target names.
+	Paths []string `json:"paths"`
+	// Repositories are the names of the repositories to query.
+	Repositories []string `json:"repositories"`
+	// Threshold is the number of repositories that must agree on the length
+	// and hashes of a target.
+	Threshold int `json:"threshold"`
+	// Terminating stops the resolution when the threshold is not met,
+	// instead of trying the following mappings.
+	Terminating bool `json:"terminating"`
+}
+
+// ParseMapFile parses and validates a map.json.
+func ParseMapFile(mapJSON []byte) (*MapFile, error) {
+	m := &MapFile{}
+	if err := json.Unmarshal(mapJSON, m); err != nil {
+		return nil, fmt.Errorf("parsing map.json: %w", err)
+	}
+	if len(m.Mapping) == 0 {
+		return nil, errors.New("map.json has no mapping")
+	}
+	for name, urls := range m.Repositories {
+		if len(urls) == 0 {
+			return nil, fmt.Errorf("repository %s has no URL", name)
+		}
+	}
+	for i, mapping := range m.Mapping {
+		if len(mapping.Paths) == 0 {
+			return nil, fmt.Errorf("mapping %d has no path", i)
+		}
+		for _, pattern := range mapping.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("mapping %d: invalid path pattern %q", i, pattern)
+			}
+		}
+		seen := make(map[string]bool, len(mapping.Repositories))
+		for _, name := range mapping.Repositories {
+			if _, ok := m.Repositories[name]; !ok {
+				return nil, fmt.Errorf("mapping %d: unknown repository %s", i, name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("mapping %d: duplicate repository %s", i, name)
+			}
+			seen[name] = true
+		}
+		if mapping.Threshold < 1 || mapping.Threshold > len(mapping.Repositories) {
+			return nil, fmt.Errorf("mapping %d: threshold %d out of range for %d repositories",
+				i, mapping.Threshold, len(mapping.Repositories))
+		}
+	}
+	return m, nil
+}
+
+// matches reports whether the target name matches any path pattern of the
+// mapping.
+func (m *Mapping) matches(name string) bool {
+	for _, pattern := range m.Paths {
+		// Patterns were validated when parsing the map.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MultiRepositoryClient resolves targets across several TUF repositories
+// according to a TAP-4 map.json, e.g. to require that the trusted root is
+// signed both by the Sigstore repository and by an internal one.
+type MultiRepositoryClient struct {
+	mapFile *MapFile
+	clients map[string]*SigstoreTufClient
+
+	// refreshes coalesces concurrent calls to Refresh.
+	refreshes refreshGroup
+}
+
+var _ root.TrustedRootProvider = (*MultiRepositoryClient)(nil)
+
+// NewMultiRepositoryClient creates a client per repository of the map.json,
+// and initializes it from its trusted root.json in roots, keyed by repository
+// name. The first URL of each repository is used as its remote, and the
+// following ones as its mirrors. With a Disk cache, each repository is cached
+// in a subdirectory of CacheLocation named after it.
+func NewMultiRepositoryClient(ctx context.Context, opts *ClientOptions, mapJSON []byte, roots map[string][]byte) (*MultiRepositoryClient, error) {
+	m, err := ParseMapFile(mapJSON)
+	if err != nil {
+		return nil, err
+	}
+	clients := make(map[string]*SigstoreTufClient, len(m.Repositories))
+	for name, urls := range m.Repositories {
+		rootJSON, ok := roots[name]
+		if !ok {
+			return nil, fmt.Errorf("no trusted root.json for repository %s", name)
+		}
+		c, err := NewSigstoreTufClient(opts)
+		if err != nil {
+			return nil, fmt.Errorf("repository %s: %w", name, err)
+		}
+		if err := c.Initialize(ctx, &RepositoryOptions{
+			Root:    rootJSON,
+			Remote:  urls[0],
+			Mirrors: urls[1:],
+			Name:    name,
+		}); err != nil {
+			return nil, fmt.Errorf("repository %s: %w", name, err)
+		}
+		clients[name] = c
+	}
+	return &MultiRepositoryClient{mapFile: m, clients: clients}, nil
+}
+
+// Refresh updates the trusted metadata of every repository from its remote,
+// as SigstoreTufClient.Refresh. Repositories are refreshed in turn, and a
+// repository failing to refresh does not stop the others from being
+// refreshed. Concurrent calls wait for a single refresh, bound to the context
+// of the call that started it.
+func (c *MultiRepositoryClient) Refresh(ctx context.Context) error {
+	return c.refreshes.do(ctx, c.refresh)
+}
+
+func (c *MultiRepositoryClient) refresh(ctx context.Context) error {
+	names := make([]string, 0, len(c.clients))
+	for name := range c.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var failed []string
+	var lastErr error
+	for _, name := range names {
+		if err := c.clients[name].Refresh(ctx); err != nil {
+			failed = append(failed, name)
+			lastErr = fmt.Errorf("repository %s: %w", name, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return lastErr
+	}
+	return fmt.Errorf("repositories %s failed to refresh, last error: %w", strings.Join(failed, ", "), lastErr)
+}
+
+// GetTarget resolves a target through the mappings of the map.json, in
+// order. For the first mapping matching the target name whose threshold of
+// repositories agree on its length and hashes, the target is retrieved from
+// one of the agreeing repositories. A terminating mapping whose threshold is
+// not met ends the resolution with an error.
+func (c *MultiRepositoryClient) GetTarget(ctx context.Context, name string) ([]byte, error) {
+	for i := range c.mapFile.Mapping {
+		mapping := &c.mapFile.Mapping[i]
+		if !mapping.matches(name) {
+			continue
+		}
+		agreeing, err := c.agreeingRepositories(ctx, mapping, name)
+		if err == nil {
+			return c.clients[agreeing[0]].GetTarget(ctx, name)
+		}
+		if mapping.Terminating {
+			return nil, fmt.Errorf("target %s: terminating mapping %d: %w", name, i, err)
+		}
+	}
+	return nil, fmt.Errorf("target %s: no mapping resolves it", name)
+}
+
+// GetTrustedRoot returns the TrustedRoot resolved through the map.json.
+func (c *MultiRepositoryClient) GetTrustedRoot(ctx context.Context) (*root.TrustedRoot, error) {
+	rootJSON, err := c.GetTarget(ctx, TrustedRootTarget)
+	if err != nil {
+		return nil, err
+	}
+	return root.NewTrustedRootFromJSON(rootJSON)
+}
+
+// agreeingRepositories returns the largest set of repositories of the mapping
+// that agree on the target, if it meets the threshold. Repositories that fail
+// to provide the target metadata do not count towards the threshold.
+func (c *MultiRepositoryClient) agreeingRepositories(ctx context.Context, mapping *Mapping, name string) ([]string, error) {
+	type group struct {
+		meta  data.TargetFileMeta
+		names []string
+	}
+	var groups []*group
+	var lastErr error
+	for _, repo := range mapping.Repositories {
+		meta, err := c.clients[repo].targetMeta(ctx, name)
+		if err != nil {
+			lastErr = fmt.Errorf("repository %s: %w", repo, err)
+			continue
+		}
+		var g *group
+		for _, candidate := range groups {
+			if util.TargetFileMetaEqual(meta, candidate.meta) == nil {
+				g = candidate
+				break
+			}
+		}
+		if g == nil {
+			g = &group{meta: meta}
+			groups = append(groups, g)
+		}
+		g.names = append(g.names, repo)
+	}
+	var best *group
+	for _, g := range groups {
+		if best == nil || len(g.names) > len(best.names) {
+			best = g
+		}
+	}
+	if best == nil || len(best.names) < mapping.Threshold {
+		agreeing := 0
+		if best != nil {
+			agreeing = len(best.names)
+		}
+		err := fmt.Errorf("%d of %d required repositories agree", agreeing, mapping.Threshold)
+		if lastErr != nil {
+			err = fmt.Errorf("%v, last error: %w", err, lastErr)
+		}
+		return nil, err
+	}
+	return best.names, nil
+}
@@ -0,0 +1,250 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestParseMapFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mapJSON string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			mapJSON: `{
+				"repositories": {"sigstore": ["https://tuf-repo-cdn.sigstore.dev"], "internal": ["https://tuf.internal"]},
+				"mapping": [{"paths": ["*"], "repositories": ["sigstore", "internal"], "threshold": 2, "terminating": true}]
+			}`,
+		},
+		{
+			name:    "no mapping",
+			mapJSON: `{"repositories": {"sigstore": ["https://tuf-repo-cdn.sigstore.dev"]}}`,
+			wantErr: true,
+		},
+		{
+			name: "repository without URL",
+			mapJSON: `{
+				"repositories": {"sigstore": []},
+				"mapping": [{"paths": ["*"], "repositories": ["sigstore"], "threshold": 1}]
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "unknown repository",
+			mapJSON: `{
+				"repositories": {"sigstore": ["https://tuf-repo-cdn.sigstore.dev"]},
+				"mapping": [{"paths": ["*"], "repositories": ["internal"], "threshold": 1}]
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "threshold too high",
+			mapJSON: `{
+				"repositories": {"sigstore": ["https://tuf-repo-cdn.sigstore.dev"]},
+				"mapping": [{"paths": ["*"], "repositories": ["sigstore"], "threshold": 2}]
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "invalid pattern",
+			mapJSON: `{
+				"repositories": {"sigstore": ["https://tuf-repo-cdn.sigstore.dev"]},
+				"mapping": [{"paths": ["["], "repositories": ["sigstore"], "threshold": 1}]
+			}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseMapFile([]byte(tc.mapJSON))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseMapFile returned %v, expected error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMultiRepositoryClient(t *testing.T) {
+	trustedRoot := tuftest.NewTrustedRootJSON(t)
+	testRepoA := tuftest.NewRepository(t)
+	testRepoA.AddTarget(TrustedRootTarget, trustedRoot, nil)
+	testRepoA.AddTarget("both.txt", []byte("a"), nil)
+	testRepoA.AddTarget("only-a.txt", []byte("a"), nil)
+	testRepoA.Publish()
+	testRepoB := tuftest.NewRepository(t)
+	testRepoB.AddTarget(TrustedRootTarget, trustedRoot, nil)
+	testRepoB.AddTarget("both.txt", []byte("b"), nil)
+	testRepoB.Publish()
+	repositories := fmt.Sprintf(`{"a": [%q], "b": [%q]}`, testRepoA.FileURL(), testRepoB.FileURL())
+	roots := map[string][]byte{"a": testRepoA.Root(), "b": testRepoB.Root()}
+
+	testCases := []struct {
+		name    string
+		mapping string
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "repositories agree",
+			mapping: `[{"paths": ["*.json"], "repositories": ["a", "b"], "threshold": 2, "terminating": true}]`,
+			target:  TrustedRootTarget,
+		},
+		{
+			name:    "repositories disagree",
+			mapping: `[{"paths": ["*.txt"], "repositories": ["a", "b"], "threshold": 2, "terminating": true}]`,
+			target:  "both.txt",
+			wantErr: true,
+		},
+		{
+			name:    "target missing from a repository",
+			mapping: `[{"paths": ["*"], "repositories": ["a", "b"], "threshold": 2, "terminating": true}]`,
+			target:  "only-a.txt",
+			wantErr: true,
+		},
+		{
+			name: "terminating mapping",
+			mapping: `[
+				{"paths": ["*.txt"], "repositories": ["a", "b"], "threshold": 2, "terminating": true},
+				{"paths": ["*"], "repositories": ["a"], "threshold": 1}
+			]`,
+			target:  "both.txt",
+			wantErr: true,
+		},
+		{
+			name: "non-terminating mapping falls through",
+			mapping: `[
+				{"paths": ["*.txt"], "repositories": ["a", "b"], "threshold": 2},
+				{"paths": ["*"], "repositories": ["b"], "threshold": 1}
+			]`,
+			target: "both.txt",
+			want:   "b",
+		},
+		{
+			name: "threshold met by some repositories",
+			mapping: `[
+				{"paths": ["only-*"], "repositories": ["b", "a"], "threshold": 1, "terminating": true}
+			]`,
+			target: "only-a.txt",
+			want:   "a",
+		},
+		{
+			name:    "no matching mapping",
+			mapping: `[{"paths": ["*.json"], "repositories": ["a"], "threshold": 1}]`,
+			target:  "both.txt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			mapJSON := fmt.Sprintf(`{"repositories": %s, "mapping": %s}`, repositories, tc.mapping)
+			c, err := NewMultiRepositoryClient(context.Background(), &ClientOptions{
+				CacheType:     Disk,
+				CacheLocation: filepath.Join(t.TempDir(), "cache"),
+			}, []byte(mapJSON), roots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := c.GetTarget(context.Background(), tc.target)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("GetTarget unexpectedly returned an error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("GetTarget returned, expected error")
+			}
+			if tc.want != "" && string(b) != tc.want {
+				t.Errorf("GetTarget returned %q, expected %q", b, tc.want)
+			}
+		})
+	}
+
+	c, err := NewMultiRepositoryClient(context.Background(), &ClientOptions{CacheType: Memory},
+		[]byte(fmt.Sprintf(`{"repositories": %s, "mapping": [{"paths": ["*"], "repositories": ["a", "b"], "threshold": 2}]}`,
+			repositories)), roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTrustedRoot(context.Background()); err != nil {
+		t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if _, err := NewMultiRepositoryClient(context.Background(), &ClientOptions{CacheType: Memory},
+		[]byte(fmt.Sprintf(`{"repositories": %s, "mapping": [{"paths": ["*"], "repositories": ["a"], "threshold": 1}]}`,
+			repositories)), map[string][]byte{"a": roots["a"]}); err == nil {
+		t.Error("NewMultiRepositoryClient returned, expected error for a repository without root")
+	}
+}
+
+func TestMultiRepositoryClientRefresh(t *testing.T) {
+	t.Parallel()
+	trustedRoot := tuftest.NewTrustedRootJSON(t)
+	testRepoA := tuftest.NewRepository(t)
+	testRepoA.AddTrustedRoot(trustedRoot)
+	testRepoA.Publish()
+	testRepoB := tuftest.NewRepository(t)
+	testRepoB.AddTrustedRoot(trustedRoot)
+	testRepoB.Publish()
+	mapJSON := fmt.Sprintf(`{
+		"repositories": {"a": [%q], "b": [%q]},
+		"mapping": [{"paths": ["*"], "repositories": ["a", "b"], "threshold": 2, "terminating": true}]
+	}`, testRepoA.FileURL(), testRepoB.FileURL())
+	c, err := NewMultiRepositoryClient(context.Background(), &ClientOptions{CacheType: Memory}, []byte(mapJSON),
+		map[string][]byte{"a": testRepoA.Root(), "b": testRepoB.Root()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrustedRoot := func(want []byte) {
+		t.Helper()
+		got, err := c.GetTrustedRoot(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantRoot, err := root.NewTrustedRootFromJSON(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := root.DiffTrustedRoots(wantRoot, got); !diff.Empty() {
+			t.Errorf("unexpected trusted root, diff: %v", diff.Changes)
+		}
+	}
+	checkTrustedRoot(trustedRoot)
+
+	newTrustedRoot := tuftest.NewTrustedRootJSON(t)
+	testRepoA.AddTrustedRoot(newTrustedRoot)
+	testRepoA.Publish()
+	testRepoB.AddTrustedRoot(newTrustedRoot)
+	testRepoB.Publish()
+	checkTrustedRoot(trustedRoot)
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkTrustedRoot(newTrustedRoot)
+}
@@ -0,0 +1,147 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// UpdateEvent describes an update of the repository that yielded a new TUF
+// root version or a different trusted root.
+type UpdateEvent struct {
+	// OldRootVersion and NewRootVersion are the versions of the TUF
+	// root.json before and after the update.
+	OldRootVersion int64 `json:"old_root_version"`
+	NewRootVersion int64 `json:"new_root_version"`
+	// Diff lists the changes to the trusted root made by the update. It is
+	// nil when the trusted root before the update could not be retrieved.
+	Diff *root.TrustedRootDiff `json:"diff,omitempty"`
+}
+
+// Subscribe registers fn to be called after every update of the repository
+// by Initialize or Refresh that yields a new TUF root version or a different
+// trusted root, e.g. to log or alert on rotations. The first initialization sets the
+// baseline and is not reported. fn is called synchronously, so it should hand
+// slow work, such as sending the event on a channel, off to another
+// goroutine. The returned function cancels the subscription.
+//
+// While there are subscribers, every update retrieves the trusted root before
+// and after it in order to compare them, and fails if the updated one cannot
+// be retrieved.
+func (s *SigstoreTufClient) Subscribe(fn func(UpdateEvent)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[int]func(UpdateEvent))
+	}
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// watching reports whether the trusted root must be compared across the next
+// update, for there are subscribers. The caller must hold updateMu for
+// writing.
+func (s *SigstoreTufClient) watching() bool {
+	if s.opts.Offline {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers) > 0
+}
+
+// previousTrustedRoot returns the trusted root before an update: the one
+// retrieved after the previous update, or else the one of the local metadata.
+// It returns nil if it cannot be retrieved. The caller must hold updateMu for
+// writing.
+func (s *SigstoreTufClient) previousTrustedRoot(ctx context.Context) *root.TrustedRoot {
+	if s.trustedRoot != nil {
+		return s.trustedRoot
+	}
+	tr, err := s.fetchTrustedRoot(ctx, s.lockedView())
+	if err != nil {
+		return nil
+	}
+	return tr
+}
+
+// updateEvent retrieves the trusted root after an update, and returns the
+// event to notify the subscribers of if the update changed it or the TUF root
+// version, or nil. The caller must hold updateMu for writing.
+func (s *SigstoreTufClient) updateEvent(ctx context.Context, wasInitialized bool, oldVersion, newVersion int64, oldRoot *root.TrustedRoot) (*UpdateEvent, error) {
+	newRoot, err := s.fetchTrustedRoot(ctx, s.lockedView())
+	if err != nil {
+		return nil, fmt.Errorf("retrieving updated trusted root: %w", err)
+	}
+	s.trustedRoot = newRoot
+	if !wasInitialized {
+		return nil, nil
+	}
+	event := &UpdateEvent{OldRootVersion: oldVersion, NewRootVersion: newVersion}
+	if oldRoot != nil {
+		event.Diff = root.DiffTrustedRoots(oldRoot, newRoot)
+	}
+	if oldVersion == newVersion && (event.Diff == nil || event.Diff.Empty()) {
+		return nil, nil
+	}
+	return event, nil
+}
+
+// notify calls the subscribers with event.
+func (s *SigstoreTufClient) notify(event UpdateEvent) {
+	s.mu.Lock()
+	subscribers := make([]func(UpdateEvent), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	s.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// rootVersion returns the version of the trusted root.json in local, or 0 if
+// there is none.
+func rootVersion(local client.LocalStore) (int64, error) {
+	meta, err := local.GetMeta()
+	if err != nil {
+		return 0, fmt.Errorf("reading local metadata: %w", err)
+	}
+	rootJSON, ok := meta["root.json"]
+	if !ok {
+		return 0, nil
+	}
+	var signed struct {
+		Signed struct {
+			Version int64 `json:"version"`
+		} `json:"signed"`
+	}
+	if err := json.Unmarshal(rootJSON, &signed); err != nil {
+		return 0, fmt.Errorf("parsing local root.json: %w", err)
+	}
+	return signed.Signed.Version, nil
+}
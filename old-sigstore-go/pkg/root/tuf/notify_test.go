@@ -0,0 +1,168 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	bootstrapRoot := testRepo.Root()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []UpdateEvent
+	unsubscribe := client.Subscribe(func(e UpdateEvent) {
+		events = append(events, e)
+	})
+	initialize := func() {
+		t.Helper()
+		if err := client.Initialize(context.Background(), &RepositoryOptions{
+			Name:   "sigstore-staging",
+			Remote: fmt.Sprintf("file://%s/repository", td),
+			Root:   bootstrapRoot,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first initialization sets the baseline.
+	initialize()
+	if len(events) != 0 {
+		t.Fatalf("expected no event on first initialization, got %v", events)
+	}
+
+	// Rotate the root and the trusted root.
+	testRepo.RotateKeys("root")
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	initialize()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event after rotation, got %d", len(events))
+	}
+	e := events[0]
+	if e.OldRootVersion != 1 || e.NewRootVersion != 2 {
+		t.Errorf("expected root versions 1 -> 2, got %d -> %d", e.OldRootVersion, e.NewRootVersion)
+	}
+	if e.Diff == nil || len(e.Diff.Changes) != 2 {
+		t.Errorf("expected the replaced transparency log in the diff, got %v", e.Diff)
+	}
+
+	// Updates without changes are not reported.
+	initialize()
+	if len(events) != 1 {
+		t.Fatalf("expected no event without changes, got %d", len(events)-1)
+	}
+
+	// Refresh reports updates too.
+	testRepo.RotateKeys("root")
+	testRepo.Publish()
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 1 event after refresh, got %d", len(events)-1)
+	}
+	if e := events[1]; e.OldRootVersion != 2 || e.NewRootVersion != 3 || e.Diff == nil || !e.Diff.Empty() {
+		t.Errorf("expected root versions 2 -> 3 without trusted root changes, got %+v", e)
+	}
+
+	unsubscribe()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	initialize()
+	if len(events) != 2 {
+		t.Errorf("expected no event after unsubscribing, got %d", len(events)-2)
+	}
+}
+
+func TestSubscribeConcurrentReads(t *testing.T) {
+	t.Parallel()
+	// Consistent snapshots keep the previous trusted root downloadable.
+	testRepo := tuftest.NewRepository(t, tuftest.WithConsistentSnapshot())
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{CacheType: Memory})
+
+	var mu sync.Mutex
+	var events []UpdateEvent
+	client.Subscribe(func(e UpdateEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})
+	checkEvents := func(want int) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if len(events) != want {
+			t.Fatalf("expected %d events, got %d", want, len(events))
+		}
+		if e := events[want-1]; e.Diff == nil || e.Diff.Empty() {
+			t.Errorf("expected the replaced trusted root in the diff, got %+v", e)
+		}
+	}
+
+	// The trusted root is compared even if it was never retrieved.
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(1)
+
+	// Reads during the update do not hide the change.
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(done)
+	wg.Wait()
+	checkEvents(2)
+}
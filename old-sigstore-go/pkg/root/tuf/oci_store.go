@@ -0,0 +1,127 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+const (
+	// ociScheme is the URL scheme of remotes in an OCI registry.
+	ociScheme = "oci"
+	// ociTitleAnnotation names the file held by a layer, as set by ORAS.
+	ociTitleAnnotation = "org.opencontainers.image.title"
+)
+
+// ociRemoteStore is a client.RemoteStore reading metadata and targets from an
+// image in an OCI registry, such as one pushed with `oras push`. Each file of
+// the repository is a layer, whose org.opencontainers.image.title annotation
+// is its path in the repository, e.g. "timestamp.json" or
+// "targets/trusted_root.json". Layers are read as stored, without
+// decompression.
+type ociRemoteStore struct {
+	ref  name.Reference
+	opts []remote.Option
+
+	// img and layers are fetched on first use.
+	img    v1.Image
+	layers map[string]v1.Descriptor
+}
+
+// newOCIRemoteStore creates a remote store for an image reference such as
+// registry.example.com/sigstore/tuf:latest. Of the HTTP options, the
+// transport and user agent apply to the requests to the registry, which are
+// authenticated with the credentials of the Docker configuration.
+func newOCIRemoteStore(ctx context.Context, reference string, opts HTTPOptions) (*ociRemoteStore, error) {
+	ref, err := name.ParseReference(reference)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCI reference %s: %w", reference, err)
+	}
+	remoteOpts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+	}
+	transport := opts.Transport
+	if opts.Client != nil && opts.Client.Transport != nil {
+		transport = opts.Client.Transport
+	}
+	if transport != nil {
+		remoteOpts = append(remoteOpts, remote.WithTransport(transport))
+	}
+	if opts.UserAgent != "" {
+		remoteOpts = append(remoteOpts, remote.WithUserAgent(opts.UserAgent))
+	}
+	return &ociRemoteStore{ref: ref, opts: remoteOpts}, nil
+}
+
+func (o *ociRemoteStore) GetMeta(name string) (io.ReadCloser, int64, error) {
+	return o.get(name)
+}
+
+func (o *ociRemoteStore) GetTarget(name string) (io.ReadCloser, int64, error) {
+	return o.get(path.Join("targets", name))
+}
+
+func (o *ociRemoteStore) get(name string) (io.ReadCloser, int64, error) {
+	if o.img == nil {
+		if err := o.fetch(); err != nil {
+			return nil, 0, err
+		}
+	}
+	desc, ok := o.layers[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return nil, 0, client.ErrNotFound{File: name}
+	}
+	layer, err := o.img.LayerByDigest(desc.Digest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s from %s: %w", name, o.ref, err)
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s from %s: %w", name, o.ref, err)
+	}
+	return rc, desc.Size, nil
+}
+
+// fetch retrieves the manifest of the image, indexing its layers by title.
+func (o *ociRemoteStore) fetch() error {
+	img, err := remote.Image(o.ref, o.opts...)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", o.ref, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", o.ref, err)
+	}
+	layers := make(map[string]v1.Descriptor, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		if title, ok := desc.Annotations[ociTitleAnnotation]; ok {
+			layers[title] = desc
+		}
+	}
+	o.img, o.layers = img, layers
+	return nil
+}
@@ -0,0 +1,124 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// pushOCIRepository pushes the files of a repository as the layers of an
+// image, as `oras push` does.
+func pushOCIRepository(t *testing.T, dir, reference string) {
+	t.Helper()
+	img := v1.Image(empty.Image)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer:       static.NewLayer(b, types.MediaType("application/octet-stream")),
+			Annotations: map[string]string{ociTitleAnnotation: filepath.ToSlash(rel)},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOCIRemoteStore(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	reference := strings.TrimPrefix(s.URL, "http://") + "/sigstore/tuf:latest"
+	pushOCIRepository(t, filepath.Join(td, "repository"), reference)
+
+	tufClient, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tufClient.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: "oci://" + reference,
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatalf("Initialize unexpectedly returned an error: %v", err)
+	}
+	if _, err := tufClient.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+
+	store, err := newOCIRemoteStore(context.Background(), reference, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notFound client.ErrNotFound
+	if _, _, err := store.GetMeta("missing.json"); !errors.As(err, &notFound) {
+		t.Errorf("GetMeta returned %v, expected not found", err)
+	}
+
+	tufClient, err = NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tufClient.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: "oci://" + strings.TrimPrefix(s.URL, "http://") + "/sigstore/tuf:missing",
+		Root:   testRepo.Root(),
+	}); err == nil {
+		t.Error("Initialize returned, expected error for a missing image")
+	}
+}
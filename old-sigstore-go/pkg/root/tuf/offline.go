@@ -0,0 +1,166 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/util"
+	"github.com/theupdateframework/go-tuf/verify"
+)
+
+var errOfflineRequiresDisk = errors.New("offline mode requires a disk cache")
+
+// ExpiredMetadataError is returned in offline mode when cached metadata
+// expired longer ago than the offline grace period.
+type ExpiredMetadataError struct {
+	Role        string
+	Expires     time.Time
+	GracePeriod time.Duration
+}
+
+func (e *ExpiredMetadataError) Error() string {
+	return fmt.Sprintf("cached %s metadata expired at %s, beyond the offline grace period of %s",
+		e.Role, e.Expires.UTC().Format(time.RFC3339), e.GracePeriod)
+}
+
+// offlineMetadata is the trusted metadata verified from the local store.
+type offlineMetadata struct {
+	root      *data.Root
+	timestamp *data.Timestamp
+	snapshot  *data.Snapshot
+	targets   *data.Targets
+	// db holds the keys and top-level roles of the root.
+	db *verify.DB
+}
+
+// loadOfflineMetadata verifies the top-level metadata in local without
+// contacting the remote. Signatures, versions and the consistency of
+// the timestamp, snapshot and targets are checked as by the TUF client, while
+// expired metadata is accepted within the offline grace period.
+func (s *SigstoreTufClient) loadOfflineMetadata(local client.LocalStore, now time.Time) (*offlineMetadata, error) {
+	meta, err := local.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("reading local metadata: %w", err)
+	}
+	m := &offlineMetadata{
+		root:      &data.Root{},
+		timestamp: &data.Timestamp{},
+		snapshot:  &data.Snapshot{},
+		targets:   &data.Targets{},
+	}
+	rootJSON, ok := meta["root.json"]
+	if !ok {
+		return nil, errors.New("no cached root.json, the cache must be populated online first")
+	}
+	// The root is verified against its own keys: it was verified against
+	// the trusted root.json when it was cached.
+	var signed data.Signed
+	if err := json.Unmarshal(rootJSON, &signed); err != nil {
+		return nil, fmt.Errorf("parsing cached root.json: %w", err)
+	}
+	if err := json.Unmarshal(signed.Signed, m.root); err != nil {
+		return nil, fmt.Errorf("parsing cached root.json: %w", err)
+	}
+	db := verify.NewDB()
+	for id, k := range m.root.Keys {
+		if err := db.AddKey(id, k); err != nil {
+			return nil, fmt.Errorf("cached root.json: %w", err)
+		}
+	}
+	for name, role := range m.root.Roles {
+		if err := db.AddRole(name, role); err != nil {
+			return nil, fmt.Errorf("cached root.json: %w", err)
+		}
+	}
+	if err := db.VerifyIgnoreExpiredCheck(&signed, "root", 0); err != nil {
+		return nil, fmt.Errorf("verifying cached root.json: %w", err)
+	}
+	if err := s.checkOfflineExpiry("root", m.root.Expires, now); err != nil {
+		return nil, err
+	}
+
+	for _, r := range []struct {
+		role    string
+		v       interface{}
+		expires func() time.Time
+	}{
+		{"timestamp", m.timestamp, func() time.Time { return m.timestamp.Expires }},
+		{"snapshot", m.snapshot, func() time.Time { return m.snapshot.Expires }},
+		{"targets", m.targets, func() time.Time { return m.targets.Expires }},
+	} {
+		b, ok := meta[r.role+".json"]
+		if !ok {
+			return nil, fmt.Errorf("no cached %s.json, the cache must be populated online first", r.role)
+		}
+		if err := db.UnmarshalIgnoreExpired(b, r.v, r.role, 0); err != nil {
+			return nil, fmt.Errorf("verifying cached %s.json: %w", r.role, err)
+		}
+		if err := s.checkOfflineExpiry(r.role, r.expires(), now); err != nil {
+			return nil, err
+		}
+	}
+
+	if sm, ok := m.timestamp.Meta["snapshot.json"]; !ok || sm.Version != m.snapshot.Version {
+		return nil, errors.New("cached snapshot.json does not match the cached timestamp.json")
+	}
+	if tm, ok := m.snapshot.Meta["targets.json"]; !ok || tm.Version != m.targets.Version {
+		return nil, errors.New("cached targets.json does not match the cached snapshot.json")
+	}
+	m.db = db
+	return m, nil
+}
+
+func (s *SigstoreTufClient) checkOfflineExpiry(role string, expires, now time.Time) error {
+	if now.After(expires.Add(s.opts.OfflineGracePeriod)) {
+		return &ExpiredMetadataError{Role: role, Expires: expires, GracePeriod: s.opts.OfflineGracePeriod}
+	}
+	return nil
+}
+
+// offlineTargets lists the top-level targets of the cached metadata in local.
+func (s *SigstoreTufClient) offlineTargets(local client.LocalStore) (data.TargetFiles, error) {
+	m, err := s.loadOfflineMetadata(local, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return m.targets.Targets, nil
+}
+
+// getOfflineTarget returns a cached target. Only targets that were retrieved
+// while online are available, along with the delegated metadata listing them.
+func (s *SigstoreTufClient) getOfflineTarget(v *view, name string) ([]byte, error) {
+	meta, _, err := s.resolveLocalTarget(v.local, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.files.readFile(targetFileName(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("target %s is not cached", name)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := util.BytesMatchLenAndHashes(b, meta.Length, meta.Hashes); err != nil {
+		return nil, fmt.Errorf("cached target %s: %w", name, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,369 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+// newOfflineCache populates a disk cache with an online client, retrieving
+// the trusted root.
+func newOfflineCache(t *testing.T) (cacheLocation string, rootJSON []byte) {
+	t.Helper()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.AddTarget("uncached.txt", []byte("uncached"), nil)
+	testRepo.Publish()
+
+	cacheLocation = filepath.Join(t.TempDir(), "cache")
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return cacheLocation, testRepo.Root()
+}
+
+func TestOffline(t *testing.T) {
+	t.Parallel()
+	cacheLocation, rootJSON := newOfflineCache(t)
+
+	if _, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory, Offline: true}); !errors.Is(err, errOfflineRequiresDisk) {
+		t.Fatalf("NewSigstoreTufClient returned %v, expected %v", err, errOfflineRequiresDisk)
+	}
+	client, err := NewSigstoreTufClient(&ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+		Offline:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The remote is unreachable, and never contacted.
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: "https://tuf.invalid",
+		Root:   rootJSON,
+	}); err != nil {
+		t.Fatalf("Initialize unexpectedly returned an error: %v", err)
+	}
+	tr, err := client.GetTrustedRoot(context.Background())
+	if err != nil {
+		t.Fatalf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if len(tr.TransparencyLogs()) != 1 {
+		t.Errorf("expected 1 transparency log, got %d", len(tr.TransparencyLogs()))
+	}
+	targets, err := client.ListTargets(context.Background())
+	if err != nil || len(targets) != 2 {
+		t.Fatalf("ListTargets returned %v, %v, expected 2 targets", targets, err)
+	}
+	if _, err := client.GetTarget(context.Background(), "uncached.txt"); err == nil {
+		t.Error("GetTarget returned, expected error for a target that was not cached")
+	}
+}
+
+func TestOfflineExpiry(t *testing.T) {
+	cacheLocation, rootJSON := newOfflineCache(t)
+	// go-tuf expires the metadata of test repositories within days.
+	testCases := []struct {
+		name        string
+		now         time.Time
+		gracePeriod time.Duration
+		wantExpired bool
+	}{
+		{
+			name: "unexpired",
+			now:  time.Now(),
+		},
+		{
+			name:        "expired",
+			now:         time.Now().Add(48 * time.Hour),
+			wantExpired: true,
+		},
+		{
+			name:        "expired within grace period",
+			now:         time.Now().Add(48 * time.Hour),
+			gracePeriod: 72 * time.Hour,
+		},
+		{
+			name:        "expired beyond grace period",
+			now:         time.Now().Add(240 * time.Hour),
+			gracePeriod: 72 * time.Hour,
+			wantExpired: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client, err := NewSigstoreTufClient(&ClientOptions{
+				CacheType:          Disk,
+				CacheLocation:      cacheLocation,
+				Offline:            true,
+				OfflineGracePeriod: tc.gracePeriod,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Initialize(context.Background(), &RepositoryOptions{
+				Name: "sigstore-staging",
+				Root: rootJSON,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.loadOfflineMetadata(client.local, tc.now)
+			var expiredErr *ExpiredMetadataError
+			if tc.wantExpired {
+				if !errors.As(err, &expiredErr) {
+					t.Fatalf("loadOfflineMetadata returned %v, expected ExpiredMetadataError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadOfflineMetadata unexpectedly returned an error: %v", err)
+			}
+		})
+	}
+}
+
+func TestOfflineInvalidCache(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(t *testing.T, cacheLocation string)
+	}{
+		{
+			name: "empty cache",
+			modify: func(t *testing.T, cacheLocation string) {
+				if err := os.RemoveAll(cacheLocation); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "missing targets",
+			modify: func(t *testing.T, cacheLocation string) {
+				if err := os.Remove(filepath.Join(cacheLocation, "sigstore-staging", "targets.json")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "tampered targets",
+			modify: func(t *testing.T, cacheLocation string) {
+				p := filepath.Join(cacheLocation, "sigstore-staging", "targets.json")
+				b, err := os.ReadFile(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b = bytes.Replace(b, []byte("uncached.txt"), []byte("tampered.txt"), 1)
+				if err := os.WriteFile(p, b, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cacheLocation, rootJSON := newOfflineCache(t)
+			tc.modify(t, cacheLocation)
+			client, err := NewSigstoreTufClient(&ClientOptions{
+				CacheType:     Disk,
+				CacheLocation: cacheLocation,
+				Offline:       true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Initialize(context.Background(), &RepositoryOptions{
+				Name:   "sigstore-staging",
+				Remote: "https://tuf.invalid",
+				Root:   rootJSON,
+			}); err == nil {
+				t.Fatal("Initialize returned, expected error")
+			}
+		})
+	}
+}
+
+func TestOfflineRootChain(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	rootV1 := testRepo.Root()
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	online := newInitializedClient(t, td, rootV1, &ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+	})
+	for i := 0; i < 2; i++ {
+		testRepo.RotateKeys("root")
+		testRepo.Publish()
+	}
+	if err := online.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := online.GetTrustedRoot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rootV3 := testRepo.Root()
+
+	otherRepo := tuftest.NewRepository(t)
+	otherRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	otherRepo.Publish()
+
+	testCases := []struct {
+		name    string
+		root    []byte
+		wantErr bool
+	}{
+		{
+			name: "cached root",
+			root: rootV3,
+		},
+		{
+			name: "root chaining to the cached root",
+			root: rootV1,
+		},
+		{
+			name:    "root of another repository",
+			root:    otherRepo.Root(),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		client, err := NewSigstoreTufClient(&ClientOptions{
+			CacheType:     Disk,
+			CacheLocation: cacheLocation,
+			Offline:       true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.Initialize(context.Background(), &RepositoryOptions{
+			Name:   "sigstore-staging",
+			Remote: "https://tuf.invalid",
+			Root:   tc.root,
+		})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: Initialize returned %v, expected error: %t", tc.name, err, tc.wantErr)
+		}
+		if err == nil {
+			if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+				t.Errorf("%s: GetTrustedRoot unexpectedly returned an error: %v", tc.name, err)
+			}
+		}
+	}
+
+	// The chain cannot be checked without the intermediate versions.
+	if err := os.Remove(filepath.Join(cacheLocation, "sigstore-staging", filepath.FromSlash(rootFileName(2)))); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSigstoreTufClient(&ClientOptions{
+		CacheType:     Disk,
+		CacheLocation: cacheLocation,
+		Offline:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: "https://tuf.invalid",
+		Root:   rootV1,
+	}); err == nil {
+		t.Error("Initialize returned, expected error for a missing root.json version")
+	}
+}
+
+func TestVerifyRootChain(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	testRepo.AddTarget("foo.txt", []byte("foo"), nil)
+	testRepo.Publish()
+	rootV1 := testRepo.Root()
+	testRepo.RotateKeys("root")
+	testRepo.Publish()
+	rootV2 := testRepo.Root()
+	otherRepo := tuftest.NewRepository(t)
+	otherRepo.AddTarget("foo.txt", []byte("foo"), nil)
+	otherRepo.Publish()
+	otherV1 := otherRepo.Root()
+	otherRepo.RotateKeys("root")
+	otherRepo.Publish()
+	otherV2 := otherRepo.Root()
+
+	testCases := []struct {
+		name    string
+		trusted []byte
+		latest  []byte
+		wantErr bool
+	}{
+		{
+			name:    "same root",
+			trusted: rootV2,
+			latest:  rootV2,
+		},
+		{
+			name:    "next version",
+			trusted: rootV1,
+			latest:  rootV2,
+		},
+		{
+			name:    "older version",
+			trusted: rootV2,
+			latest:  rootV1,
+			wantErr: true,
+		},
+		{
+			name:    "same version of another repository",
+			trusted: rootV1,
+			latest:  otherV1,
+			wantErr: true,
+		},
+		{
+			name:    "next version of another repository",
+			trusted: rootV1,
+			latest:  otherV2,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := verifyRootChain(tc.trusted, tc.latest, newMemoryFileStore())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("verifyRootChain returned %v, expected error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
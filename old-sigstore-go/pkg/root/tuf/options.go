@@ -15,6 +15,11 @@
 
 package tuf
 
+import (
+	"net/http"
+	"time"
+)
+
 // CacheKind is used to designate an on-disk or in-memory cache.
 type CacheKind int
 
@@ -31,23 +36,103 @@ type ClientOptions struct {
 	// CacheLocation is the location for the local cache.
 	// Only applies when CacheType is Disk.
 	// This directory will contain the metadata and targets cache for the TUF
-	// client.
+	// client, in a subdirectory per repository named after
+	// RepositoryOptions.Name. It is read when the client is initialized, and
+	// written through on updates, while reads are served from memory.
 	CacheLocation string
+
+	// Offline loads the trusted metadata and targets from the Disk cache
+	// without contacting the remote. The cache must have been populated by
+	// an online client, and only holds the targets it retrieved. The cached
+	// root.json must be the trusted root.json of the repository, or chain
+	// from it through the versions the online client walked.
+	Offline bool
+
+	// OfflineGracePeriod is how long past its expiry cached metadata may be
+	// used in offline mode. Metadata expired for longer is rejected with an
+	// ExpiredMetadataError.
+	// Default: 0, expired metadata is rejected.
+	OfflineGracePeriod time.Duration
+
+	// ExpiryWarningThreshold is how long before its expiry metadata is
+	// reported as expiring soon by MetadataStatus.
+	// Default: DefaultExpiryWarningThreshold.
+	ExpiryWarningThreshold time.Duration
 }
 
+// DefaultExpiryWarningThreshold is how long before its expiry metadata is
+// reported as expiring soon when ClientOptions.ExpiryWarningThreshold is
+// unset.
+const DefaultExpiryWarningThreshold = 24 * time.Hour
+
 // RepositoryOptions specify options for initializing a particular
 // repository in the TUF client.
 // Specifies a root.json, a remote, and a name.
 //
-// TODO: Replace with a map.json for a multi-repository setup.
+// For a multi-repository setup described by a map.json, use a
+// MultiRepositoryClient.
 type RepositoryOptions struct {
 	// The trusted root.json
 	Root []byte
 
-	// The location of the remote repository.
+	// The location of the remote repository: an HTTP(S) URL, a file:// URL,
+	// or an oci:// reference to an image in an OCI registry, whose layers
+	// are the files of the repository named by their
+	// org.opencontainers.image.title annotation.
 	Remote string
 
-	// The name of the repository, used to populate the map.json. TODO: Make this
-	// optional and use digest of the root.
+	// Mirrors are other locations of the remote repository. They are tried
+	// in turn when an update or a download from the remote fails, including
+	// when it serves metadata or targets that fail verification. Remotes are
+	// tried from the one with the fewest consecutive failures, in configured
+	// order otherwise. HTTP.Headers are not sent to mirrors on another host
+	// than Remote.
+	Mirrors []string
+
+	// The name of the repository, used to populate the map.json, and to name
+	// the subdirectory of ClientOptions.CacheLocation caching it, so that
+	// several repositories may share a cache location. It must not contain
+	// path separators.
+	// Default: the hex-encoded SHA-256 digest of Root.
 	Name string
+
+	// HTTP configures the requests to HTTP(S) remotes.
+	HTTP HTTPOptions
 }
+
+// HTTPOptions configure the requests made to HTTP(S) remotes. Of these, only
+// the transport and the user agent apply to OCI registries.
+type HTTPOptions struct {
+	// Client is the HTTP client making the requests.
+	// Default: http.DefaultClient, or a client using Transport if set.
+	Client *http.Client
+
+	// Transport is the transport of the default client. It is ignored when
+	// Client is set.
+	Transport http.RoundTripper
+
+	// UserAgent is the User-Agent header of the requests.
+	UserAgent string
+
+	// Headers are added to every request to the host of the remote, e.g. to
+	// authenticate to a private repository. They are not sent to mirrors on
+	// other hosts, which may not be trusted with the credentials.
+	Headers http.Header
+
+	// Timeout bounds each request, including reading the response body.
+	// Default: 0, requests are only bound to the context of the operation.
+	Timeout time.Duration
+
+	// Retries is the number of times a request failing with a 5xx status
+	// is retried.
+	Retries int
+
+	// RetryBackoff is the delay before the first retry, doubled for every
+	// following one.
+	// Default: DefaultRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// DefaultRetryBackoff is the delay before the first retry of a request when
+// HTTPOptions.RetryBackoff is unset.
+const DefaultRetryBackoff = time.Second
@@ -0,0 +1,227 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package publish maintains the TUF repository of a private Sigstore
+// deployment, distributing its trusted root and signing configuration to
+// Sigstore TUF clients.
+//
+// A repository is a directory holding the private keys of the top-level roles
+// in keys, the changes waiting to be published in staged, and the published
+// metadata and targets in repository, which is served to clients as is. Each
+// role is signed by a single key.
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	sigstoretuf "github.com/sigstore/sigstore-go/pkg/root/tuf"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/internal/rotate"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+// Roles are the top-level roles of a repository.
+var Roles = []string{"root", "targets", "snapshot", "timestamp"}
+
+// Options configures a Repository.
+type Options struct {
+	// Passphrase returns the passphrase encrypting the keys of a role. The
+	// keys are stored unencrypted if it is nil.
+	Passphrase util.PassphraseFunc
+
+	// RootExpires, TargetsExpires, SnapshotExpires and TimestampExpires are
+	// the validity periods of newly signed metadata of each role. The go-tuf
+	// defaults of 365, 90, 7 and 1 days apply when zero.
+	RootExpires      time.Duration
+	TargetsExpires   time.Duration
+	SnapshotExpires  time.Duration
+	TimestampExpires time.Duration
+}
+
+// expires returns the expiration time of metadata of a role signed now.
+func (o *Options) expires(role string) time.Time {
+	var d time.Duration
+	switch role {
+	case "root":
+		d = o.RootExpires
+	case "targets":
+		d = o.TargetsExpires
+	case "snapshot":
+		d = o.SnapshotExpires
+	case "timestamp":
+		d = o.TimestampExpires
+	}
+	if d == 0 {
+		return data.DefaultExpires(role)
+	}
+	return time.Now().Add(d)
+}
+
+// Repository is a TUF repository being published. It is not safe for
+// concurrent use, and a directory must not be modified by several
+// Repositories at once.
+type Repository struct {
+	dir   string
+	store tuf.LocalStore
+	repo  *tuf.Repo
+	opts  Options
+}
+
+// Init creates a repository in dir, generating a key for each top-level role,
+// and stages its first root.json. Consistent snapshots publish metadata and
+// targets under versioned and hash-prefixed names as well, so that clients
+// never observe a partially published update.
+func Init(dir string, consistentSnapshot bool, opts *Options) (*Repository, error) {
+	r, err := Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := r.store.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := meta["root.json"]; ok {
+		return nil, fmt.Errorf("repository already initialized in %s", dir)
+	}
+	if err := r.repo.Init(consistentSnapshot); err != nil {
+		return nil, fmt.Errorf("initializing repository: %w", err)
+	}
+	for _, role := range Roles {
+		if _, err := r.repo.GenKeyWithExpires(role, r.opts.expires("root")); err != nil {
+			return nil, fmt.Errorf("generating %s key: %w", role, err)
+		}
+	}
+	return r, nil
+}
+
+// Open opens the repository in dir.
+func Open(dir string, opts *Options) (*Repository, error) {
+	store := tuf.FileSystemStore(dir, opts.Passphrase)
+	repo, err := tuf.NewRepo(store)
+	if err != nil {
+		return nil, fmt.Errorf("opening repository: %w", err)
+	}
+	return &Repository{dir: dir, store: store, repo: repo, opts: *opts}, nil
+}
+
+// Dir returns the directory of the repository.
+func (r *Repository) Dir() string {
+	return r.dir
+}
+
+// AddTrustedRoot stages the trusted_root.json target, after checking that it
+// parses as a trusted root.
+func (r *Repository) AddTrustedRoot(trustedRootJSON []byte) error {
+	if _, err := root.NewTrustedRootFromJSON(trustedRootJSON); err != nil {
+		return fmt.Errorf("invalid trusted root: %w", err)
+	}
+	return r.AddTarget(sigstoretuf.TrustedRootTarget, trustedRootJSON, nil)
+}
+
+// AddSigningConfig stages the signing configuration target, after checking
+// that it parses as a signing configuration.
+func (r *Repository) AddSigningConfig(signingConfigJSON []byte) error {
+	if _, err := root.NewSigningConfigFromJSON(signingConfigJSON); err != nil {
+		return fmt.Errorf("invalid signing config: %w", err)
+	}
+	return r.AddTarget(sigstoretuf.SigningConfigTarget, signingConfigJSON, nil)
+}
+
+// AddTarget stages a target with optional custom metadata, replacing any
+// target with the same name.
+func (r *Repository) AddTarget(name string, b []byte, custom json.RawMessage) error {
+	name = util.NormalizeTarget(name)
+	targetPath := filepath.Join(r.dir, "staged", "targets", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(targetPath, b, 0o644); err != nil {
+		return err
+	}
+	if err := r.repo.AddTargetWithExpires(name, custom, r.opts.expires("targets")); err != nil {
+		return fmt.Errorf("adding target %s: %w", name, err)
+	}
+	return nil
+}
+
+// RotateKey replaces the key of a top-level role with a new one, and stages
+// root.json with an incremented version. When the root key is rotated, the
+// new root.json is signed by both the previous and the new key, so that
+// clients trusting the previous one accept it. When the targets key is
+// rotated, the targets metadata is signed again with the new key.
+func (r *Repository) RotateKey(role string) error {
+	repo, err := rotate.Key(r.store, r.repo, role, r.opts.expires)
+	if err != nil {
+		return err
+	}
+	r.repo = repo
+	return nil
+}
+
+// Publish signs new snapshot and timestamp metadata, and publishes the staged
+// metadata and targets.
+func (r *Repository) Publish() error {
+	if err := r.repo.SnapshotWithExpires(r.opts.expires("snapshot")); err != nil {
+		return fmt.Errorf("signing snapshot.json: %w", err)
+	}
+	return r.publishTimestamp()
+}
+
+// ResignTimestamp publishes new timestamp metadata, extending its expiration,
+// without any other change. It fails if changes are staged, which must be
+// published instead.
+func (r *Repository) ResignTimestamp() error {
+	for _, role := range Roles {
+		if r.store.FileIsStaged(role + ".json") {
+			return fmt.Errorf("%s.json has unpublished changes", role)
+		}
+	}
+	return r.publishTimestamp()
+}
+
+func (r *Repository) publishTimestamp() error {
+	if err := r.repo.TimestampWithExpires(r.opts.expires("timestamp")); err != nil {
+		return fmt.Errorf("signing timestamp.json: %w", err)
+	}
+	if err := r.repo.Commit(); err != nil {
+		return fmt.Errorf("publishing: %w", err)
+	}
+	return nil
+}
+
+// ResignTimestamps calls ResignTimestamp every interval, so that the
+// published timestamp never expires as long as the interval is shorter than
+// its validity period, until ctx is done or re-signing fails.
+func (r *Repository) ResignTimestamps(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := r.ResignTimestamp(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf"
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+var signingConfigJSON = []byte(`{
+	"mediaType": "application/vnd.dev.sigstore.signingconfig.v0.2+json",
+	"caUrls": [{"url": "https://fulcio.example.com", "majorApiVersion": 1, "validFor": {"start": "2023-01-01T00:00:00Z"}}],
+	"rekorTlogUrls": [{"url": "https://rekor.example.com", "majorApiVersion": 1, "validFor": {"start": "2023-01-01T00:00:00Z"}}],
+	"rekorTlogConfig": {"selector": "ANY"}
+}`)
+
+// newPublishedRepository initializes and publishes a repository with a trusted
+// root and a signing config.
+func newPublishedRepository(t *testing.T, opts *Options) *Repository {
+	t.Helper()
+	r, err := Init(t.TempDir(), true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddTrustedRoot(tuftest.NewTrustedRootJSON(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddSigningConfig(signingConfigJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Publish(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func published(t *testing.T, r *Repository, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(r.Dir(), "repository", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// signedVersion returns the version of signed metadata.
+func signedVersion(t *testing.T, b []byte) int64 {
+	t.Helper()
+	s := &data.Signed{}
+	if err := json.Unmarshal(b, s); err != nil {
+		t.Fatal(err)
+	}
+	var meta struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal(s.Signed, &meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta.Version
+}
+
+// newClient initializes a Sigstore TUF client with the published root.json.
+func newClient(t *testing.T, r *Repository) *tuf.SigstoreTufClient {
+	t.Helper()
+	client, err := tuf.NewSigstoreTufClient(&tuf.ClientOptions{CacheType: tuf.Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &tuf.RepositoryOptions{
+		Name:   "private",
+		Remote: fmt.Sprintf("file://%s/repository", r.Dir()),
+		Root:   published(t, r, "root.json"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestPublish(t *testing.T) {
+	t.Parallel()
+	r := newPublishedRepository(t, &Options{})
+	client := newClient(t, r)
+	if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+		t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+	}
+	if _, err := client.GetSigningConfig(context.Background()); err != nil {
+		t.Errorf("GetSigningConfig unexpectedly returned an error: %v", err)
+	}
+
+	if _, err := Init(r.Dir(), false, &Options{}); err == nil {
+		t.Error("Init returned, expected error for an initialized repository")
+	}
+	if err := r.AddTrustedRoot([]byte("{}")); err == nil {
+		t.Error("AddTrustedRoot returned, expected error for an invalid trusted root")
+	}
+	if err := r.AddSigningConfig([]byte("{}")); err == nil {
+		t.Error("AddSigningConfig returned, expected error for an invalid signing config")
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	for _, role := range Roles {
+		role := role
+		t.Run(role, func(t *testing.T) {
+			t.Parallel()
+			r := newPublishedRepository(t, &Options{})
+			client := newClient(t, r)
+
+			// The repository is reopened as by separate invocations of the
+			// command.
+			reopened, err := Open(r.Dir(), &Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := reopened.RotateKey(role); err != nil {
+				t.Fatalf("RotateKey unexpectedly returned an error: %v", err)
+			}
+			if err := reopened.Publish(); err != nil {
+				t.Fatalf("Publish unexpectedly returned an error: %v", err)
+			}
+			if v := signedVersion(t, published(t, r, "root.json")); v != 2 {
+				t.Errorf("expected root version 2, got %d", v)
+			}
+			if err := client.Refresh(context.Background()); err != nil {
+				t.Fatalf("Refresh unexpectedly returned an error: %v", err)
+			}
+			if _, err := client.GetTrustedRoot(context.Background()); err != nil {
+				t.Errorf("GetTrustedRoot unexpectedly returned an error: %v", err)
+			}
+		})
+	}
+
+	t.Run("delegated role", func(t *testing.T) {
+		t.Parallel()
+		r := newPublishedRepository(t, &Options{})
+		if err := r.RotateKey("team"); err == nil {
+			t.Error("RotateKey returned, expected error for a role that is not top-level")
+		}
+	})
+}
+
+func TestResignTimestamp(t *testing.T) {
+	t.Parallel()
+	r := newPublishedRepository(t, &Options{TimestampExpires: time.Hour})
+	before := published(t, r, "timestamp.json")
+	if err := r.ResignTimestamp(); err != nil {
+		t.Fatalf("ResignTimestamp unexpectedly returned an error: %v", err)
+	}
+	after := published(t, r, "timestamp.json")
+	if v := signedVersion(t, after); v != signedVersion(t, before)+1 {
+		t.Errorf("expected timestamp version %d, got %d", signedVersion(t, before)+1, v)
+	}
+	newClient(t, r)
+
+	if err := r.AddSigningConfig(signingConfigJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ResignTimestamp(); err == nil {
+		t.Error("ResignTimestamp returned, expected error for unpublished changes")
+	}
+}
+
+func TestResignTimestamps(t *testing.T) {
+	t.Parallel()
+	r := newPublishedRepository(t, &Options{TimestampExpires: time.Hour})
+	version := signedVersion(t, published(t, r, "timestamp.json"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	if err := r.ResignTimestamps(ctx, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ResignTimestamps returned %v, expected the context error", err)
+	}
+	if v := signedVersion(t, published(t, r, "timestamp.json")); v <= version+1 {
+		t.Errorf("expected the timestamp to be re-signed several times, got version %d from %d", v, version)
+	}
+	newClient(t, r)
+}
+
+func TestExpires(t *testing.T) {
+	t.Parallel()
+	opts := &Options{TimestampExpires: time.Hour}
+	if got := opts.expires("timestamp"); time.Until(got) > time.Hour {
+		t.Errorf("unexpected timestamp expiration %v", got)
+	}
+	if got, want := opts.expires("snapshot"), data.DefaultExpires("snapshot"); got.Sub(want) > time.Second {
+		t.Errorf("expected default snapshot expiration %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,313 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+	"github.com/theupdateframework/go-tuf/verify"
+)
+
+// RootChainReport is the chain of root.json versions an update walked, from
+// the trusted root.json it started from to the latest one, which may be the
+// same. It is meant to be archived as evidence of the trust in the
+// repository, and is marshaled as JSON.
+type RootChainReport struct {
+	// Repository is the name of the repository.
+	Repository string `json:"repository"`
+	// Time is the time the update completed.
+	Time time.Time `json:"time"`
+	// Roots are the versions walked, in order. The first one was trusted as
+	// is, and each following one was signed by a threshold of the root keys
+	// of the previous one and by a threshold of its own.
+	Roots []RootReport `json:"roots"`
+}
+
+// RootReport is a version of root.json in a chain.
+type RootReport struct {
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+	// Digest is the hex-encoded SHA-256 digest of the root.json file.
+	Digest string `json:"digest"`
+	// PreviousSigners are the root keys of the previous version whose
+	// signatures verified. It is nil for the first version.
+	PreviousSigners *SignerReport `json:"previous_signers,omitempty"`
+	// Signers are the root keys of the version whose signatures verified.
+	Signers SignerReport `json:"signers"`
+	// KeyChanges are the changes of keys and thresholds of the roles from the
+	// previous version.
+	KeyChanges []KeyChange `json:"key_changes,omitempty"`
+}
+
+// SignerReport are the key IDs of a root role whose signatures verified,
+// and its threshold.
+type SignerReport struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+// KeyChange is a change of the keys or threshold of a role between two
+// versions of root.json.
+type KeyChange struct {
+	Role              string   `json:"role"`
+	Added             []string `json:"added,omitempty"`
+	Removed           []string `json:"removed,omitempty"`
+	PreviousThreshold int      `json:"previous_threshold"`
+	Threshold         int      `json:"threshold"`
+}
+
+// RootChain returns the chain of root.json versions walked by the last
+// successful Initialize or Refresh, or nil if there was none or in offline
+// mode. The report is shared and must not be modified.
+func (s *SigstoreTufClient) RootChain() *RootChainReport {
+	s.updateMu.RLock()
+	defer s.updateMu.RUnlock()
+	return s.rootChain
+}
+
+// rootLog collects the root.json versions persisted by TUF clients during an
+// update. The TUF client persists each version once it verified it.
+type rootLog struct {
+	roots []json.RawMessage
+}
+
+// rootRecorder is a client.LocalStore recording the root.json versions
+// persisted to it in a rootLog.
+type rootRecorder struct {
+	client.LocalStore
+	log *rootLog
+}
+
+func (r *rootRecorder) SetMeta(name string, meta json.RawMessage) error {
+	if err := r.LocalStore.SetMeta(name, meta); err != nil {
+		return err
+	}
+	if name == "root.json" {
+		r.log.roots = append(r.log.roots, append(json.RawMessage(nil), meta...))
+	}
+	return nil
+}
+
+// newRootChainReport reports the chain from the trusted root.json through the
+// persisted ones.
+func newRootChainReport(repository string, trusted json.RawMessage, persisted []json.RawMessage) (*RootChainReport, error) {
+	report := &RootChainReport{Repository: repository, Time: time.Now()}
+	var prev *signedRoot
+	for _, b := range append([]json.RawMessage{trusted}, persisted...) {
+		root, err := parseSignedRoot(b)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		r := RootReport{
+			Version: root.meta.Version,
+			Expires: root.meta.Expires,
+			Digest:  hex.EncodeToString(sum[:]),
+			Signers: root.signers(root.signed),
+		}
+		if prev != nil {
+			previousSigners := prev.signers(root.signed)
+			r.PreviousSigners = &previousSigners
+			r.KeyChanges = keyChanges(prev.meta, root.meta)
+		}
+		report.Roots = append(report.Roots, r)
+		prev = root
+	}
+	return report, nil
+}
+
+// signedRoot is a parsed root.json.
+type signedRoot struct {
+	signed *data.Signed
+	meta   *data.Root
+}
+
+func parseSignedRoot(b json.RawMessage) (*signedRoot, error) {
+	s := &data.Signed{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parsing root.json: %w", err)
+	}
+	meta := &data.Root{}
+	if err := json.Unmarshal(s.Signed, meta); err != nil {
+		return nil, fmt.Errorf("parsing root.json: %w", err)
+	}
+	return &signedRoot{signed: s, meta: meta}, nil
+}
+
+// verifies reports whether a threshold of the root keys of r signed s.
+func (r *signedRoot) verifies(s *data.Signed) bool {
+	signers := r.signers(s)
+	return signers.Threshold > 0 && len(signers.KeyIDs) >= signers.Threshold
+}
+
+// signers returns the root keys of r whose signatures of s verify.
+func (r *signedRoot) signers(s *data.Signed) SignerReport {
+	report := SignerReport{KeyIDs: []string{}}
+	role, ok := r.meta.Roles["root"]
+	if !ok {
+		return report
+	}
+	report.Threshold = role.Threshold
+	roleKeys := make(map[string]bool, len(role.KeyIDs))
+	for _, id := range role.KeyIDs {
+		roleKeys[id] = true
+	}
+	seen := make(map[string]bool)
+	for _, sig := range s.Signatures {
+		key, ok := r.meta.Keys[sig.KeyID]
+		if !roleKeys[sig.KeyID] || !ok || seen[sig.KeyID] {
+			continue
+		}
+		verifier, err := keys.GetVerifier(key)
+		if err != nil {
+			continue
+		}
+		if err := verify.VerifySignature(s.Signed, sig.Signature, verifier); err != nil {
+			continue
+		}
+		// A key may have several IDs.
+		for _, id := range key.IDs() {
+			seen[id] = true
+		}
+		report.KeyIDs = append(report.KeyIDs, sig.KeyID)
+	}
+	sort.Strings(report.KeyIDs)
+	return report
+}
+
+// keyChanges returns the changes of keys and thresholds of the roles between
+// two versions of root.json, ordered by role.
+func keyChanges(prev, next *data.Root) []KeyChange {
+	roles := make(map[string]bool)
+	for role := range prev.Roles {
+		roles[role] = true
+	}
+	for role := range next.Roles {
+		roles[role] = true
+	}
+	var changes []KeyChange
+	for role := range roles {
+		change := KeyChange{Role: role}
+		var prevIDs, nextIDs []string
+		if r, ok := prev.Roles[role]; ok {
+			prevIDs, change.PreviousThreshold = r.KeyIDs, r.Threshold
+		}
+		if r, ok := next.Roles[role]; ok {
+			nextIDs, change.Threshold = r.KeyIDs, r.Threshold
+		}
+		change.Added = difference(nextIDs, prevIDs)
+		change.Removed = difference(prevIDs, nextIDs)
+		if len(change.Added) > 0 || len(change.Removed) > 0 || change.PreviousThreshold != change.Threshold {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Role < changes[j].Role
+	})
+	return changes
+}
+
+// difference returns the sorted elements of a not in b.
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !inB[s] {
+			diff = append(diff, s)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// storeRootHistory stores root.json versions in files, through which offline
+// mode checks that the cached root.json chains from the trusted one.
+func storeRootHistory(files fileStore, roots []json.RawMessage) error {
+	for _, b := range roots {
+		root, err := parseSignedRoot(b)
+		if err != nil {
+			return err
+		}
+		if err := files.writeFile(rootFileName(root.meta.Version), b); err != nil {
+			return fmt.Errorf("storing root.json version %d: %w", root.meta.Version, err)
+		}
+	}
+	return nil
+}
+
+// verifyRootChain checks that latest is the trusted root.json, or chains from
+// it through the root.json versions stored in files: each version must be
+// signed by a threshold of the root keys of the previous one and of its own,
+// as the TUF client checked when it walked the chain.
+func verifyRootChain(trusted, latest json.RawMessage, files fileStore) error {
+	if bytes.Equal(trusted, latest) {
+		return nil
+	}
+	prev, err := parseSignedRoot(trusted)
+	if err != nil {
+		return fmt.Errorf("trusted %w", err)
+	}
+	last, err := parseSignedRoot(latest)
+	if err != nil {
+		return fmt.Errorf("cached %w", err)
+	}
+	switch {
+	case last.meta.Version < prev.meta.Version:
+		return fmt.Errorf("cached root.json version %d is older than the trusted root.json version %d",
+			last.meta.Version, prev.meta.Version)
+	case last.meta.Version == prev.meta.Version:
+		if !bytes.Equal(last.signed.Signed, prev.signed.Signed) {
+			return errors.New("cached root.json does not match the trusted root.json")
+		}
+		return nil
+	}
+	for v := prev.meta.Version + 1; v <= last.meta.Version; v++ {
+		next := last
+		if v < last.meta.Version {
+			b, err := files.readFile(rootFileName(v))
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("root.json version %d is not cached, the cache must be updated online first", v)
+			} else if err != nil {
+				return err
+			}
+			if next, err = parseSignedRoot(b); err != nil {
+				return fmt.Errorf("cached %w", err)
+			}
+			if next.meta.Version != v {
+				return fmt.Errorf("cached root.json version %d has version %d", v, next.meta.Version)
+			}
+		}
+		if !prev.verifies(next.signed) || !next.verifies(next.signed) {
+			return fmt.Errorf("cached root.json version %d does not chain from the trusted root.json", v)
+		}
+		prev = next
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+// rootKeyIDs returns the key IDs of a role in a root.json.
+func rootKeyIDs(t *testing.T, b []byte, role string) []string {
+	t.Helper()
+	root, err := parseSignedRoot(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root.meta.Roles[role].KeyIDs
+}
+
+func TestRootChain(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	testRepo.AddTrustedRoot(tuftest.NewTrustedRootJSON(t))
+	testRepo.Publish()
+	rootV1 := testRepo.Root()
+	testRepo.RotateKeys("root")
+	testRepo.Publish()
+	rootV2 := testRepo.Root()
+	testRepo.RotateKeys("timestamp")
+	testRepo.Publish()
+	rootV3 := testRepo.Root()
+
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.RootChain() != nil {
+		t.Error("RootChain returned a report before initialization")
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: testRepo.FileURL(),
+		Root:   rootV1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := client.RootChain()
+	if report == nil || report.Repository != "sigstore-staging" || len(report.Roots) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, r := range report.Roots {
+		if r.Version != int64(i+1) {
+			t.Errorf("root %d: unexpected version %d", i, r.Version)
+		}
+		if len(r.Signers.KeyIDs) != 1 || r.Signers.Threshold != 1 {
+			t.Errorf("root %d: unexpected signers %+v", i, r.Signers)
+		}
+	}
+	if report.Roots[0].PreviousSigners != nil || report.Roots[0].KeyChanges != nil {
+		t.Errorf("unexpected previous signers or key changes for the trusted root: %+v", report.Roots[0])
+	}
+
+	// Version 2 rotated the root key, and was signed by the previous one.
+	v2 := report.Roots[1]
+	if v2.PreviousSigners == nil || !reflect.DeepEqual(v2.PreviousSigners.KeyIDs, rootKeyIDs(t, rootV1, "root")) {
+		t.Errorf("expected version 2 signed by the root key of version 1, got %+v", v2.PreviousSigners)
+	}
+	if !reflect.DeepEqual(v2.Signers.KeyIDs, rootKeyIDs(t, rootV2, "root")) {
+		t.Errorf("expected version 2 signed by its root key, got %+v", v2.Signers)
+	}
+	wantChanges := []KeyChange{{
+		Role:              "root",
+		Added:             rootKeyIDs(t, rootV2, "root"),
+		Removed:           rootKeyIDs(t, rootV1, "root"),
+		PreviousThreshold: 1,
+		Threshold:         1,
+	}}
+	if !reflect.DeepEqual(v2.KeyChanges, wantChanges) {
+		t.Errorf("unexpected key changes for version 2: %+v", v2.KeyChanges)
+	}
+
+	// Version 3 rotated the timestamp key.
+	v3 := report.Roots[2]
+	if len(v3.KeyChanges) != 1 || v3.KeyChanges[0].Role != "timestamp" {
+		t.Errorf("unexpected key changes for version 3: %+v", v3.KeyChanges)
+	}
+	if !reflect.DeepEqual(v3.KeyChanges[0].Added, rootKeyIDs(t, rootV3, "timestamp")) {
+		t.Errorf("unexpected added timestamp keys %v", v3.KeyChanges[0].Added)
+	}
+
+	// The report is archived as JSON.
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived RootChainReport
+	if err := json.Unmarshal(b, &archived); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(archived.Roots, report.Roots) {
+		t.Errorf("report changed through JSON: %s", b)
+	}
+
+	// A refresh starts from the latest trusted root.json.
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if report := client.RootChain(); len(report.Roots) != 1 || report.Roots[0].Version != 3 {
+		t.Errorf("unexpected report after refresh %+v", report)
+	}
+}
+
+func TestKeyChanges(t *testing.T) {
+	t.Parallel()
+	role := func(threshold int, keyIDs ...string) *data.Role {
+		return &data.Role{KeyIDs: keyIDs, Threshold: threshold}
+	}
+	testCases := []struct {
+		name string
+		prev map[string]*data.Role
+		next map[string]*data.Role
+		want []KeyChange
+	}{
+		{
+			name: "unchanged",
+			prev: map[string]*data.Role{"root": role(1, "a"), "targets": role(1, "b")},
+			next: map[string]*data.Role{"root": role(1, "a"), "targets": role(1, "b")},
+		},
+		{
+			name: "key added and threshold raised",
+			prev: map[string]*data.Role{"root": role(1, "a")},
+			next: map[string]*data.Role{"root": role(2, "b", "a")},
+			want: []KeyChange{{Role: "root", Added: []string{"b"}, PreviousThreshold: 1, Threshold: 2}},
+		},
+		{
+			name: "keys rotated in several roles",
+			prev: map[string]*data.Role{"timestamp": role(1, "c"), "snapshot": role(1, "d")},
+			next: map[string]*data.Role{"timestamp": role(1, "e"), "snapshot": role(1, "f")},
+			want: []KeyChange{
+				{Role: "snapshot", Added: []string{"f"}, Removed: []string{"d"}, PreviousThreshold: 1, Threshold: 1},
+				{Role: "timestamp", Added: []string{"e"}, Removed: []string{"c"}, PreviousThreshold: 1, Threshold: 1},
+			},
+		},
+		{
+			name: "role removed",
+			prev: map[string]*data.Role{"mirror": role(1, "g")},
+			next: map[string]*data.Role{},
+			want: []KeyChange{{Role: "mirror", Removed: []string{"g"}, PreviousThreshold: 1}},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := keyChanges(&data.Root{Roles: tc.prev}, &data.Root{Roles: tc.next})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -16,13 +16,22 @@
 package tuf
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/theupdateframework/go-tuf/client"
-	tuf_filejsonstore "github.com/theupdateframework/go-tuf/client/filejsonstore"
 )
 
 var (
@@ -30,31 +39,221 @@ var (
 	errUnknownCacheLocation = errors.New("unknown cache location")
 )
 
-// localStoreFromOpts creates a local store depending on the TUF configuration
-// and uses the RepositoryOptions to name the metadata directory.
-func localStoreFromOpts(opts *ClientOptions) (client.LocalStore, error) {
+// checkCacheOptions checks that the cache configured by the options can be
+// created.
+func checkCacheOptions(opts *ClientOptions) error {
 	switch opts.CacheType {
 	case Disk:
 		if opts.CacheLocation == "" {
-			return nil, errUnknownCacheLocation
+			return errUnknownCacheLocation
 		}
-		return tuf_filejsonstore.NewFileJSONStore(opts.CacheLocation)
+		return nil
 	case Memory:
-		return client.MemoryLocalStore(), nil
+		return nil
 	}
-	return nil, errUnknownCacheType
+	return errUnknownCacheType
+}
+
+// localStoreFromOpts creates a local store depending on the TUF configuration
+// and uses the name of the repository to name the metadata directory, a
+// subdirectory of CacheLocation. A Disk cache is served from memory, and
+// written through to that directory. The files cached next to the metadata
+// are stored in a file store, in subdirectories of that directory with a Disk
+// cache.
+func localStoreFromOpts(opts *ClientOptions, name string) (client.LocalStore, fileStore, error) {
+	if err := checkCacheOptions(opts); err != nil {
+		return nil, nil, err
+	}
+	if opts.CacheType == Memory {
+		return newMemoryStore(), newMemoryFileStore(), nil
+	}
+	disk, err := newDiskStore(filepath.Join(opts.CacheLocation, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	local, err := newSyncedStore(disk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return local, &diskFileStore{dir: disk.dir}, nil
+}
+
+// repositoryName returns the name of the repository, which defaults to the
+// hex-encoded SHA-256 digest of its trusted root.json.
+func repositoryName(opts *RepositoryOptions) (string, error) {
+	if opts.Name == "" {
+		digest := sha256.Sum256(opts.Root)
+		return hex.EncodeToString(digest[:]), nil
+	}
+	if opts.Name == "." || opts.Name == ".." || strings.ContainsAny(opts.Name, `/\`) {
+		return "", fmt.Errorf("invalid repository name %q", opts.Name)
+	}
+	return opts.Name, nil
 }
 
 // remoteStoreFromOpts creates the remote store using the RepositoryOptions.
-// local files may be specified using the file URI scheme.
-func remoteStoreFromOpts(repoOpts *RepositoryOptions) (client.RemoteStore, error) {
+// local files may be specified using the file URI scheme, and images in an
+// OCI registry using the oci scheme, e.g. oci://registry.example.com/tuf:v1.
+// Every request made through the remote store is bound to ctx.
+func remoteStoreFromOpts(ctx context.Context, repoOpts *RepositoryOptions) (client.RemoteStore, error) {
 	u, err := url.ParseRequestURI(repoOpts.Remote)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL %s: %w", repoOpts.Remote, err)
 	}
+	if u.Scheme == ociScheme {
+		return newOCIRemoteStore(ctx, strings.TrimPrefix(repoOpts.Remote, ociScheme+"://"), repoOpts.HTTP)
+	}
 	if u.Scheme != "file" {
-		return client.HTTPRemoteStore(repoOpts.Remote, nil, nil)
+		if !strings.HasPrefix(u.Scheme, "http") {
+			return nil, client.ErrInvalidURL{URL: repoOpts.Remote}
+		}
+		return newHTTPRemoteStore(ctx, strings.TrimSuffix(repoOpts.Remote, "/"), repoOpts.HTTP), nil
 	}
 	// Use local filesystem for remote.
-	return client.NewFileRemoteStore(os.DirFS(u.Path), "")
+	remote, err := client.NewFileRemoteStore(os.DirFS(u.Path), "")
+	if err != nil {
+		return nil, err
+	}
+	return &contextRemoteStore{ctx: ctx, remote: remote}, nil
+}
+
+// httpRemoteStore is a client.RemoteStore fetching metadata and targets over
+// HTTP. Unlike client.HTTPRemoteStore, requests are bound to a context so that
+// callers can cancel them or set deadlines.
+type httpRemoteStore struct {
+	ctx     context.Context
+	baseURL string
+	client  *http.Client
+	opts    HTTPOptions
+}
+
+func newHTTPRemoteStore(ctx context.Context, baseURL string, opts HTTPOptions) *httpRemoteStore {
+	c := opts.Client
+	if c == nil {
+		c = http.DefaultClient
+		if opts.Transport != nil {
+			c = &http.Client{Transport: opts.Transport}
+		}
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	return &httpRemoteStore{ctx: ctx, baseURL: baseURL, client: c, opts: opts}
+}
+
+func (h *httpRemoteStore) GetMeta(name string) (io.ReadCloser, int64, error) {
+	return h.get(name)
+}
+
+func (h *httpRemoteStore) GetTarget(name string) (io.ReadCloser, int64, error) {
+	return h.get(path.Join("targets", name))
+}
+
+// get fetches a file, retrying server errors with an exponential backoff.
+func (h *httpRemoteStore) get(name string) (io.ReadCloser, int64, error) {
+	backoff := h.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, size, err := h.getOnce(name)
+		var statusErr *httpStatusError
+		if err == nil || !errors.As(err, &statusErr) || statusErr.code < 500 || attempt >= h.opts.Retries {
+			return body, size, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-h.ctx.Done():
+			timer.Stop()
+			return nil, 0, h.ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (h *httpRemoteStore) getOnce(name string) (io.ReadCloser, int64, error) {
+	ctx, cancel := h.ctx, context.CancelFunc(func() {})
+	if h.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(h.ctx, h.opts.Timeout)
+	}
+	u := h.baseURL + "/" + strings.TrimPrefix(name, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	for k, values := range h.opts.Headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if h.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", h.opts.UserAgent)
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		cancel()
+		return nil, 0, client.ErrNotFound{File: name}
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		cancel()
+		return nil, 0, &url.Error{
+			Op:  http.MethodGet,
+			URL: u,
+			Err: &httpStatusError{code: res.StatusCode},
+		}
+	}
+	// The timeout covers reading the body, and ends when it is closed.
+	body := &cancelReadCloser{ReadCloser: res.Body, cancel: cancel}
+	size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 0)
+	if err != nil {
+		return body, -1, nil
+	}
+	return body, size, nil
+}
+
+// httpStatusError is an unexpected HTTP response status.
+type httpStatusError struct {
+	code int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d", e.code)
+}
+
+// cancelReadCloser cancels the context of a request once its response body is
+// closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// contextRemoteStore fails requests to a remote store that cannot be
+// interrupted once its context is done.
+type contextRemoteStore struct {
+	ctx    context.Context
+	remote client.RemoteStore
+}
+
+func (c *contextRemoteStore) GetMeta(name string) (io.ReadCloser, int64, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return c.remote.GetMeta(name)
+}
+
+func (c *contextRemoteStore) GetTarget(name string) (io.ReadCloser, int64, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return c.remote.GetTarget(name)
 }
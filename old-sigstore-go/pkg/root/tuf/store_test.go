@@ -16,11 +16,23 @@
 package tuf
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+	"github.com/theupdateframework/go-tuf/client"
 )
 
 func TestLocalStoreFromOpts(t *testing.T) {
@@ -75,7 +87,7 @@ func TestLocalStoreFromOpts(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			_, err := localStoreFromOpts(tc.opts)
+			_, _, err := localStoreFromOpts(tc.opts, "sigstore-staging")
 			if err != nil {
 				if tc.wantError == nil {
 					t.Fatalf("localStoreFromOpts unexpectedly returned an error: %v", err)
@@ -129,13 +141,33 @@ func TestRemoteStoreFromOpts(t *testing.T) {
 				Remote: "http://abc",
 			},
 		},
+		{
+			name: "oci remote",
+			opts: &RepositoryOptions{
+				Remote: "oci://registry.example.com/sigstore/tuf:latest",
+			},
+		},
+		{
+			name: "oci remote bad reference",
+			opts: &RepositoryOptions{
+				Remote: "oci://registry.example.com/Sigstore/tuf:latest",
+			},
+			wantError: true,
+		},
+		{
+			name: "unsupported scheme",
+			opts: &RepositoryOptions{
+				Remote: "ftp://abc",
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tc := range testCases {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			_, err := remoteStoreFromOpts(tc.opts)
+			_, err := remoteStoreFromOpts(context.Background(), tc.opts)
 			if err != nil {
 				if !tc.wantError {
 					t.Fatalf("remoteStoreFromOpts unexpectedly returned an error: %v", err)
@@ -148,3 +180,209 @@ func TestRemoteStoreFromOpts(t *testing.T) {
 		})
 	}
 }
+
+// roundTripperFunc is an http.RoundTripper calling a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPRemoteStore(t *testing.T) {
+	testCases := []struct {
+		name string
+		// failures is the number of 503 responses before success.
+		failures  int32
+		delay     time.Duration
+		opts      HTTPOptions
+		wantCalls int32
+		wantError bool
+	}{
+		{
+			name:      "success",
+			wantCalls: 1,
+		},
+		{
+			name:      "headers and user agent",
+			opts:      HTTPOptions{UserAgent: "test-agent", Headers: http.Header{"Authorization": {"Bearer token"}}},
+			wantCalls: 1,
+		},
+		{
+			name:      "retried server errors",
+			failures:  2,
+			opts:      HTTPOptions{Retries: 2, RetryBackoff: time.Millisecond},
+			wantCalls: 3,
+		},
+		{
+			name:      "retries exhausted",
+			failures:  3,
+			opts:      HTTPOptions{Retries: 2, RetryBackoff: time.Millisecond},
+			wantCalls: 3,
+			wantError: true,
+		},
+		{
+			name:      "no retries by default",
+			failures:  1,
+			wantCalls: 1,
+			wantError: true,
+		},
+		{
+			name:      "request timeout",
+			delay:     time.Second,
+			opts:      HTTPOptions{Timeout: 20 * time.Millisecond},
+			wantCalls: 1,
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var calls int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				if n <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				if tc.delay > 0 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(tc.delay):
+					}
+					return
+				}
+				if tc.opts.UserAgent != "" && r.UserAgent() != tc.opts.UserAgent {
+					t.Errorf("unexpected user agent %q", r.UserAgent())
+				}
+				if got := r.Header.Get("Authorization"); got != tc.opts.Headers.Get("Authorization") {
+					t.Errorf("unexpected authorization header %q", got)
+				}
+				fmt.Fprint(w, "{}")
+			}))
+			t.Cleanup(s.Close)
+
+			remote := newHTTPRemoteStore(context.Background(), s.URL, tc.opts)
+			body, _, err := remote.GetMeta("root.json")
+			if err == nil {
+				_, err = io.ReadAll(body)
+				body.Close()
+			}
+			if (err != nil) != tc.wantError {
+				t.Errorf("GetMeta returned %v, expected error: %t", err, tc.wantError)
+			}
+			if got := atomic.LoadInt32(&calls); got != tc.wantCalls {
+				t.Errorf("expected %d requests, got %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestHTTPRemoteStoreTransport(t *testing.T) {
+	t.Parallel()
+	var requested string
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	})
+	remote, err := remoteStoreFromOpts(context.Background(), &RepositoryOptions{
+		Remote: "https://tuf.example",
+		HTTP:   HTTPOptions{Transport: transport},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := remote.GetTarget("trusted_root.json"); !errors.As(err, &client.ErrNotFound{}) {
+		t.Errorf("GetTarget returned %v, expected ErrNotFound", err)
+	}
+	if requested != "https://tuf.example/targets/trusted_root.json" {
+		t.Errorf("unexpected request to %s", requested)
+	}
+}
+
+func TestRepositoryName(t *testing.T) {
+	rootJSON := []byte(`{"signed": {}}`)
+	digest := sha256.Sum256(rootJSON)
+	testCases := []struct {
+		name    string
+		opts    *RepositoryOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "name",
+			opts: &RepositoryOptions{Name: "sigstore-staging", Root: rootJSON},
+			want: "sigstore-staging",
+		},
+		{
+			name: "default name",
+			opts: &RepositoryOptions{Root: rootJSON},
+			want: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:    "path separator",
+			opts:    &RepositoryOptions{Name: "../sigstore", Root: rootJSON},
+			wantErr: true,
+		},
+		{
+			name:    "parent directory",
+			opts:    &RepositoryOptions{Name: "..", Root: rootJSON},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := repositoryName(tc.opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("repositoryName returned %v, expected error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("repositoryName returned %q, expected %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSharedCacheLocation(t *testing.T) {
+	t.Parallel()
+	cacheLocation := filepath.Join(t.TempDir(), "cache")
+	var names []string
+	for _, target := range []string{"staging.txt", "prod.txt"} {
+		testRepo := tuftest.NewRepository(t)
+		td := testRepo.Dir()
+		testRepo.AddTarget(target, []byte(target), nil)
+		testRepo.Publish()
+		rootJSON := testRepo.Root()
+		digest := sha256.Sum256(rootJSON)
+		names = append(names, hex.EncodeToString(digest[:]))
+
+		// The repositories are named after the digest of their root, and
+		// do not clobber each other's metadata.
+		for i := 0; i < 2; i++ {
+			client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Disk, CacheLocation: cacheLocation})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Initialize(context.Background(), &RepositoryOptions{
+				Remote: fmt.Sprintf("file://%s/repository", td),
+				Root:   rootJSON,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := client.GetTarget(context.Background(), target); err != nil || string(b) != target {
+				t.Fatalf("GetTarget returned %q, %v, expected %q", b, err, target)
+			}
+		}
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(cacheLocation, name, "root.json")); err != nil {
+			t.Errorf("repository %s not cached in its subdirectory: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import "encoding/json"
+
+// syncedStore is a client.LocalStore serving metadata from memory, and
+// writing it through to a disk store. It is hydrated from the disk store when
+// created, and again when locked, since other processes sharing the cache may
+// have updated it in the meantime.
+type syncedStore struct {
+	disk   *diskStore
+	memory *memoryStore
+}
+
+var _ sharedStore = (*syncedStore)(nil)
+
+func newSyncedStore(disk *diskStore) (*syncedStore, error) {
+	s := &syncedStore{disk: disk, memory: newMemoryStore()}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replaces the metadata in memory with the content of the disk store.
+func (s *syncedStore) load() error {
+	meta, err := s.disk.GetMeta()
+	if err != nil {
+		return err
+	}
+	s.memory.replace(meta)
+	return nil
+}
+
+func (s *syncedStore) GetMeta() (map[string]json.RawMessage, error) {
+	return s.memory.GetMeta()
+}
+
+// SetMeta persists the metadata to disk before serving it from memory, so
+// that a failed write leaves both unchanged.
+func (s *syncedStore) SetMeta(name string, meta json.RawMessage) error {
+	if err := s.disk.SetMeta(name, meta); err != nil {
+		return err
+	}
+	return s.memory.SetMeta(name, meta)
+}
+
+func (s *syncedStore) DeleteMeta(name string) error {
+	if err := s.disk.DeleteMeta(name); err != nil {
+		return err
+	}
+	return s.memory.DeleteMeta(name)
+}
+
+func (s *syncedStore) Close() error {
+	return s.disk.Close()
+}
+
+func (s *syncedStore) lock() (func() error, error) {
+	unlock, err := s.disk.lock()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+func (s *syncedStore) clear() error {
+	if err := s.disk.clear(); err != nil {
+		return err
+	}
+	return s.load()
+}
+
+func (s *syncedStore) repair() error {
+	if err := s.disk.repair(); err != nil {
+		return err
+	}
+	return s.load()
+}
@@ -0,0 +1,105 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSyncedStore(t *testing.T, dir string) *syncedStore {
+	t.Helper()
+	disk, err := newDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSyncedStore(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSyncedStore(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "cache")
+	s := newTestSyncedStore(t, dir)
+	if err := s.SetMeta("root.json", []byte(`{"signed": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMeta("../root.json", []byte(`{}`)); err == nil {
+		t.Error("SetMeta returned, expected error")
+	}
+
+	// Updates are written through to disk.
+	b, err := os.ReadFile(filepath.Join(dir, "root.json"))
+	if err != nil || string(b) != `{"signed": {}}` {
+		t.Fatalf("unexpected file content %q: %v", b, err)
+	}
+
+	// Reads are served from memory.
+	if err := os.Remove(filepath.Join(dir, "root.json")); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := s.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 1 || string(meta["root.json"]) != `{"signed": {}}` {
+		t.Errorf("unexpected metadata %v", meta)
+	}
+
+	// A cold start hydrates from disk.
+	if err := s.SetMeta("timestamp.json", []byte(`{"signed": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	meta, err = newTestSyncedStore(t, dir).GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 1 || meta["timestamp.json"] == nil {
+		t.Errorf("unexpected hydrated metadata %v", meta)
+	}
+
+	if err := s.DeleteMeta("timestamp.json"); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := s.GetMeta(); meta["timestamp.json"] != nil {
+		t.Error("metadata not deleted from memory")
+	}
+}
+
+func TestSyncedStoreLockReloads(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "cache")
+	s1 := newTestSyncedStore(t, dir)
+	s2 := newTestSyncedStore(t, dir)
+	if err := s1.SetMeta("root.json", []byte(`{"signed": {}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if meta, _ := s2.GetMeta(); len(meta) != 0 {
+		t.Fatalf("unexpected metadata before lock %v", meta)
+	}
+	unlock, err := s2.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if meta, _ := s2.GetMeta(); meta["root.json"] == nil {
+		t.Error("metadata updated by another store not reloaded when locking")
+	}
+}
@@ -0,0 +1,164 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+// TargetInfo describes a target of the repository, as listed in the trusted
+// targets metadata.
+type TargetInfo struct {
+	Name   string `json:"name"`
+	Length int64  `json:"length"`
+	// Hashes maps hash algorithms to hex-encoded digests of the target.
+	Hashes map[string]string `json:"hashes"`
+	// Custom is the custom metadata of the target, if any.
+	Custom json.RawMessage `json:"custom,omitempty"`
+}
+
+// ListTargets lists the top-level targets of the repository, ordered by
+// name. It makes no network call. Targets delegated to other roles are not
+// listed, but can be looked up with ResolveTarget.
+func (s *SigstoreTufClient) ListTargets(ctx context.Context) ([]TargetInfo, error) {
+	v, err := s.view()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := s.targets(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]TargetInfo, 0, len(targets))
+	for name, meta := range targets {
+		infos = append(infos, newTargetInfo(name, meta))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+func newTargetInfo(name string, meta data.TargetFileMeta) TargetInfo {
+	info := TargetInfo{
+		Name:   name,
+		Length: meta.Length,
+		Hashes: make(map[string]string, len(meta.Hashes)),
+	}
+	for alg, digest := range meta.Hashes {
+		info.Hashes[alg] = hex.EncodeToString(digest)
+	}
+	if meta.Custom != nil {
+		info.Custom = *meta.Custom
+	}
+	return info
+}
+
+// GetTarget returns the content of a target, after verifying its length and
+// hashes against the trusted targets metadata. Verified targets are cached
+// next to the local metadata, and served from the cache as long as they match
+// the metadata, so that repeated reads make no network call.
+func (s *SigstoreTufClient) GetTarget(ctx context.Context, name string) ([]byte, error) {
+	v, err := s.view()
+	if err != nil {
+		return nil, err
+	}
+	defer s.persist(v)
+	return s.getTarget(ctx, v, name)
+}
+
+// getTarget retrieves a target with the metadata of v.
+func (s *SigstoreTufClient) getTarget(ctx context.Context, v *view, name string) ([]byte, error) {
+	if s.opts.Offline {
+		return s.getOfflineTarget(v, name)
+	}
+	meta, err := s.lookupTarget(ctx, v, name)
+	if err != nil {
+		return nil, err
+	}
+	fileName := targetFileName(name)
+	if b, err := v.files.readFile(fileName); err == nil && util.BytesMatchLenAndHashes(b, meta.Length, meta.Hashes) == nil {
+		return b, nil
+	}
+
+	dest := &bufferDestination{}
+	if err := s.withMirrors(ctx, v.local, v.opts, func(c *client.Client) error {
+		dest.Reset()
+		if err := c.Download(name, dest); err != nil {
+			return contextError(ctx, err)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("downloading target %s: %w", name, err)
+	}
+	b := dest.Bytes()
+	// The target was verified, so failing to cache it only costs a download
+	// on the next read.
+	_ = v.files.writeFile(fileName, b)
+	return b, nil
+}
+
+// targetMeta returns the trusted metadata of a target.
+func (s *SigstoreTufClient) targetMeta(ctx context.Context, name string) (data.TargetFileMeta, error) {
+	v, err := s.view()
+	if err != nil {
+		return data.TargetFileMeta{}, err
+	}
+	defer s.persist(v)
+	if s.opts.Offline {
+		meta, _, err := s.resolveLocalTarget(v.local, name, time.Now())
+		return meta, err
+	}
+	return s.lookupTarget(ctx, v, name)
+}
+
+// lookupTarget returns the trusted metadata of a target with the TUF client,
+// which downloads the delegated metadata it needs into v, falling back to the
+// mirrors.
+func (s *SigstoreTufClient) lookupTarget(ctx context.Context, v *view, name string) (data.TargetFileMeta, error) {
+	var meta data.TargetFileMeta
+	var notFound error
+	if err := s.withMirrors(ctx, v.local, v.opts, func(c *client.Client) error {
+		var err error
+		meta, err = c.Target(name)
+		notFound = nil
+		if client.IsNotFound(err) {
+			// The target is missing from the verified metadata, which
+			// is not the fault of the remote.
+			notFound = err
+			return nil
+		}
+		if err != nil {
+			return contextError(ctx, err)
+		}
+		return nil
+	}); err != nil {
+		return data.TargetFileMeta{}, fmt.Errorf("looking up target %s: %w", name, err)
+	}
+	if notFound != nil {
+		return data.TargetFileMeta{}, fmt.Errorf("looking up target %s: %w", name, notFound)
+	}
+	return meta, nil
+}
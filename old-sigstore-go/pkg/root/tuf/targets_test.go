@@ -0,0 +1,184 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/tuftest"
+)
+
+func TestListTargets(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTarget("b.txt", []byte("b"), nil)
+	testRepo.AddTarget("dir/a.txt", []byte("a"), []byte(`{"usage": "test"}`))
+	testRepo.Publish()
+
+	client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{CacheType: Memory})
+	targets, err := client.ListTargets(context.Background())
+	if err != nil {
+		t.Fatalf("ListTargets unexpectedly returned an error: %v", err)
+	}
+	if len(targets) != 2 || targets[0].Name != "b.txt" || targets[1].Name != "dir/a.txt" {
+		t.Fatalf("unexpected targets %+v", targets)
+	}
+	if targets[1].Length != 1 || targets[1].Hashes["sha512"] == "" {
+		t.Errorf("unexpected length and hashes %+v", targets[1])
+	}
+	if string(targets[1].Custom) != `{"usage":"test"}` {
+		t.Errorf("unexpected custom metadata %s", targets[1].Custom)
+	}
+}
+
+func TestGetTarget(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cache CacheKind
+	}{
+		{
+			name:  "memory",
+			cache: Memory,
+		},
+		{
+			name:  "disk",
+			cache: Disk,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testRepo := tuftest.NewRepository(t)
+			td := testRepo.Dir()
+			testRepo.AddTarget("dir/target.txt", []byte("content"), nil)
+			testRepo.Publish()
+
+			client := newInitializedClient(t, td, testRepo.Root(), &ClientOptions{
+				CacheType:     tc.cache,
+				CacheLocation: filepath.Join(t.TempDir(), "cache"),
+			})
+			if _, err := client.GetTarget(context.Background(), "missing.txt"); err == nil {
+				t.Fatal("GetTarget returned, expected error for missing target")
+			}
+			b, err := client.GetTarget(context.Background(), "dir/target.txt")
+			if err != nil {
+				t.Fatalf("GetTarget unexpectedly returned an error: %v", err)
+			}
+			if !bytes.Equal(b, []byte("content")) {
+				t.Fatalf("unexpected target content %q", b)
+			}
+
+			// Repeated reads are served from the cache.
+			remoteTarget := filepath.Join(td, "repository", "targets", "dir", "target.txt")
+			if err := os.Remove(remoteTarget); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := client.GetTarget(context.Background(), "dir/target.txt"); err != nil || !bytes.Equal(b, []byte("content")) {
+				t.Fatalf("GetTarget returned %q, %v, expected the cached target", b, err)
+			}
+
+			// Cached targets that no longer match the metadata are
+			// downloaded again.
+			if err := client.files.writeFile(targetFileName("dir/target.txt"), []byte("tampered")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.GetTarget(context.Background(), "dir/target.txt"); err == nil {
+				t.Fatal("GetTarget returned, expected error for tampered cache and missing remote")
+			}
+		})
+	}
+}
+
+func TestGetTargetDoesNotBlockRefresh(t *testing.T) {
+	t.Parallel()
+	testRepo := tuftest.NewRepository(t)
+	td := testRepo.Dir()
+	testRepo.AddTarget("slow.txt", []byte("slow"), nil)
+	testRepo.Publish()
+
+	// The download of the target hangs until released.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	files := http.FileServer(http.Dir(filepath.Join(td, "repository")))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/targets/slow.txt" {
+			close(started)
+			<-release
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	client, err := NewSigstoreTufClient(&ClientOptions{CacheType: Memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: s.URL,
+		Root:   testRepo.Root(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := client.GetTarget(context.Background(), "slow.txt")
+		got <- err
+	}()
+	<-started
+	refreshed := make(chan error, 1)
+	go func() {
+		refreshed <- client.Refresh(context.Background())
+	}()
+	select {
+	case err := <-refreshed:
+		if err != nil {
+			t.Errorf("Refresh unexpectedly returned an error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Refresh waited for the download of a target")
+	}
+	close(release)
+	if err := <-got; err != nil {
+		t.Errorf("GetTarget unexpectedly returned an error: %v", err)
+	}
+}
+
+func newInitializedClient(t *testing.T, td string, rootJSON []byte, opts *ClientOptions) *SigstoreTufClient {
+	t.Helper()
+	client, err := NewSigstoreTufClient(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Initialize(context.Background(), &RepositoryOptions{
+		Name:   "sigstore-staging",
+		Remote: fmt.Sprintf("file://%s/repository", td),
+		Root:   rootJSON,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
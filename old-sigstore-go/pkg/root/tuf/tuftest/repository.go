@@ -0,0 +1,414 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tuftest builds signed TUF repositories for testing integrations
+// with Sigstore TUF clients. A Repository is written to a temporary
+// directory, can be served over HTTP with httptest, and can be modified
+// between updates of a client: targets are added and published, keys are
+// rotated, metadata is made to expire and targets are delegated.
+//
+// The methods of a Repository fail the test on error, and must be called from
+// the goroutine running the test.
+package tuftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root/tuf/internal/rotate"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+	"github.com/theupdateframework/go-tuf/pkg/targets"
+	"github.com/theupdateframework/go-tuf/sign"
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+// TrustedRootTarget is the name of the TUF target containing the trusted root.
+const TrustedRootTarget = "trusted_root.json"
+
+// Repository is a TUF repository with root, targets, snapshot and timestamp
+// roles, and any delegated targets roles, each signed by a single ed25519 key.
+type Repository struct {
+	t      testing.TB
+	dir    string
+	store  tuf.LocalStore
+	repo   *tuf.Repo
+	server *httptest.Server
+}
+
+type config struct {
+	consistentSnapshot bool
+}
+
+// Option configures a Repository.
+type Option func(*config)
+
+// WithConsistentSnapshot creates a repository with consistent snapshots, where
+// metadata is also published with versioned file names and targets with
+// hash-prefixed file names.
+func WithConsistentSnapshot() Option {
+	return func(c *config) {
+		c.consistentSnapshot = true
+	}
+}
+
+// NewRepository initializes a repository in a temporary directory of the test,
+// generating a key for each top-level role. Nothing is published until
+// Publish is called.
+func NewRepository(t testing.TB, opts ...Option) *Repository {
+	t.Helper()
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	dir := t.TempDir()
+	store := tuf.FileSystemStore(dir, nil)
+	repo, err := tuf.NewRepo(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Init(c.consistentSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range topLevelRoles {
+		if _, err := repo.GenKey(role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Repository{t: t, dir: dir, store: store, repo: repo}
+}
+
+// topLevelRoles are the roles whose keys are listed in root.json.
+var topLevelRoles = []string{"root", "targets", "snapshot", "timestamp"}
+
+// Dir returns the directory of the repository. Published files are in its
+// repository subdirectory, and staged files in its staged subdirectory.
+func (r *Repository) Dir() string {
+	return r.dir
+}
+
+// FileURL returns the file:// URL of the published repository.
+func (r *Repository) FileURL() string {
+	return fmt.Sprintf("file://%s", filepath.ToSlash(filepath.Join(r.dir, "repository")))
+}
+
+// Serve serves the published repository over HTTP until the end of the test,
+// and returns its URL. Files published later are served as well.
+func (r *Repository) Serve() string {
+	if r.server == nil {
+		r.server = httptest.NewServer(http.FileServer(http.Dir(filepath.Join(r.dir, "repository"))))
+		r.t.Cleanup(r.server.Close)
+	}
+	return r.server.URL
+}
+
+// Repo returns the underlying go-tuf repository, for modifications not covered
+// by Repository. It is replaced by RotateKeys and SetExpires.
+func (r *Repository) Repo() *tuf.Repo {
+	return r.repo
+}
+
+// AddTarget stages a target with optional custom metadata. It is signed by
+// the most deeply delegated role whose paths match the name, or by the
+// top-level targets role.
+func (r *Repository) AddTarget(name string, data []byte, custom json.RawMessage) {
+	r.t.Helper()
+	r.stageTarget(name, data)
+	if err := r.repo.AddTarget(name, custom); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// AddTargetToRole stages a target with optional custom metadata, signed by
+// the given targets role, which must be delegated the name.
+func (r *Repository) AddTargetToRole(role, name string, data []byte, custom json.RawMessage) {
+	r.t.Helper()
+	r.stageTarget(name, data)
+	if err := r.repo.AddTargetToPreferredRole(name, custom, role); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// AddTrustedRoot stages the trusted_root.json target.
+func (r *Repository) AddTrustedRoot(trustedRootJSON []byte) {
+	r.t.Helper()
+	r.AddTarget(TrustedRootTarget, trustedRootJSON, nil)
+}
+
+func (r *Repository) stageTarget(name string, data []byte) {
+	r.t.Helper()
+	targetPath := filepath.Join(r.dir, "staged", "targets", filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(targetPath, data, 0o600); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Publish signs new snapshot and timestamp metadata, and commits the staged
+// metadata and targets to the published repository.
+func (r *Repository) Publish() {
+	r.t.Helper()
+	if err := r.repo.Snapshot(); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := r.repo.Timestamp(); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := r.repo.Commit(); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Root returns the current root.json, staged or published, to initialize
+// clients with.
+func (r *Repository) Root() []byte {
+	r.t.Helper()
+	return r.meta("root.json")
+}
+
+// Meta returns the current metadata of a role, staged or published.
+func (r *Repository) Meta(role string) []byte {
+	r.t.Helper()
+	return r.meta(role + ".json")
+}
+
+func (r *Repository) meta(name string) []byte {
+	r.t.Helper()
+	meta, err := r.store.GetMeta()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	b, ok := meta[name]
+	if !ok {
+		r.t.Fatalf("repository has no %s", name)
+	}
+	return b
+}
+
+// RotateKeys replaces the key of a top-level role with a new one, and stages
+// root.json with an incremented version. A new root.json is signed by both
+// the previous and the new root keys, so that clients trusting the previous
+// one accept it. The rotation is visible to clients once published.
+func (r *Repository) RotateKeys(role string) {
+	r.t.Helper()
+	repo, err := rotate.Key(r.store, r.repo, role, data.DefaultExpires)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.repo = repo
+}
+
+// SetExpires re-signs the published metadata of a role with the given
+// expiration time, which may be in the past, and an incremented version. The
+// snapshot and timestamp metadata are re-signed to reference it. The metadata
+// is published immediately, without going through Publish.
+// Further changes to a repository with expired metadata fail until the
+// expiration is set in the future again.
+func (r *Repository) SetExpires(role string, expires time.Time) {
+	r.t.Helper()
+	expires = expires.UTC().Round(time.Second)
+	name := role + ".json"
+	b := r.published(name)
+	switch role {
+	case "root":
+		root := &data.Root{}
+		r.unmarshalSigned(b, root)
+		root.Expires = expires
+		root.Version++
+		r.publishSigned(name, root, root.Version)
+	case "timestamp":
+		timestamp := &data.Timestamp{}
+		r.unmarshalSigned(b, timestamp)
+		timestamp.Expires = expires
+		timestamp.Version++
+		r.publishSigned(name, timestamp, timestamp.Version)
+	case "snapshot":
+		snapshot := &data.Snapshot{}
+		r.unmarshalSigned(b, snapshot)
+		snapshot.Expires = expires
+		snapshot.Version++
+		r.updateTimestamp(r.publishSigned(name, snapshot, snapshot.Version))
+	default:
+		t := &data.Targets{}
+		r.unmarshalSigned(b, t)
+		t.Expires = expires
+		t.Version++
+		r.updateSnapshot(name, r.publishSigned(name, t, t.Version))
+	}
+	r.reload()
+}
+
+// updateSnapshot re-signs the published snapshot metadata referencing the new
+// metadata of a targets role, and the timestamp metadata referencing it.
+func (r *Repository) updateSnapshot(name string, b []byte) {
+	r.t.Helper()
+	snapshot := &data.Snapshot{}
+	r.unmarshalSigned(r.published("snapshot.json"), snapshot)
+	meta, err := util.GenerateSnapshotFileMeta(bytes.NewReader(b))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	snapshot.Meta[name] = meta
+	snapshot.Version++
+	r.updateTimestamp(r.publishSigned("snapshot.json", snapshot, snapshot.Version))
+}
+
+// updateTimestamp re-signs the published timestamp metadata referencing the
+// new snapshot metadata.
+func (r *Repository) updateTimestamp(b []byte) {
+	r.t.Helper()
+	timestamp := &data.Timestamp{}
+	r.unmarshalSigned(r.published("timestamp.json"), timestamp)
+	meta, err := util.GenerateTimestampFileMeta(bytes.NewReader(b))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	timestamp.Meta["snapshot.json"] = meta
+	timestamp.Version++
+	r.publishSigned("timestamp.json", timestamp, timestamp.Version)
+}
+
+// publishSigned signs metadata with the keys of its role, writes it to the
+// published repository, versioned as Commit would, and returns the file.
+func (r *Repository) publishSigned(name string, v interface{}, version int64) []byte {
+	r.t.Helper()
+	role := name[:len(name)-len(".json")]
+	signers, err := r.store.GetSigners(role)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	s, err := sign.Marshal(v, signers...)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	paths := []string{name}
+	if name == "root.json" || (name != "timestamp.json" && r.rootMeta().ConsistentSnapshot) {
+		paths = append(paths, util.VersionedPath(name, version))
+	}
+	for _, p := range paths {
+		if err := os.WriteFile(filepath.Join(r.dir, "repository", p), b, 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	return b
+}
+
+// published returns the published metadata file with the given name.
+func (r *Repository) published(name string) []byte {
+	r.t.Helper()
+	b, err := os.ReadFile(filepath.Join(r.dir, "repository", name))
+	if err != nil {
+		r.t.Fatalf("%s is not published: %v", name, err)
+	}
+	return b
+}
+
+func (r *Repository) unmarshalSigned(b []byte, v interface{}) {
+	r.t.Helper()
+	s := &data.Signed{}
+	if err := json.Unmarshal(b, s); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := json.Unmarshal(s.Signed, v); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// rootMeta returns the current root metadata.
+func (r *Repository) rootMeta() *data.Root {
+	r.t.Helper()
+	root := &data.Root{}
+	r.unmarshalSigned(r.Root(), root)
+	return root
+}
+
+// reload loads the go-tuf repository again from the files, which were
+// modified behind its back.
+func (r *Repository) reload() {
+	r.t.Helper()
+	repo, err := tuf.NewRepo(r.store)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.repo = repo
+}
+
+// Delegate delegates the target paths, which may contain shell-style
+// wildcards, from the delegator targets role to a new role with its own key.
+// A terminating delegation stops the search for targets matching its paths
+// at the role. The delegation is visible to clients once published.
+func (r *Repository) Delegate(delegator, role string, paths []string, terminating bool) {
+	r.t.Helper()
+	signer := r.newDelegatedKey(role)
+	if err := r.repo.AddDelegatedRole(delegator, data.DelegatedRole{
+		Name:        role,
+		KeyIDs:      signer.PublicData().IDs(),
+		Threshold:   1,
+		Terminating: terminating,
+		Paths:       paths,
+	}, []*data.PublicKey{signer.PublicData()}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// DelegateHashBins delegates all target paths from the top-level targets role
+// to 2^bitLen roles named after prefix, each responsible for the targets
+// whose path hash starts with its prefixes. The bins share a key. It returns
+// the names of the roles.
+func (r *Repository) DelegateHashBins(prefix string, bitLen int) []string {
+	r.t.Helper()
+	bins, err := targets.NewHashBins(prefix, bitLen)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	names := make([]string, 0, bins.NumBins())
+	for i := uint64(0); i < bins.NumBins(); i++ {
+		names = append(names, bins.GetBin(i).RoleName())
+	}
+	signer := r.newDelegatedKey(names...)
+	if err := r.repo.AddDelegatedRolesForPathHashBins("targets", bins, []*data.PublicKey{signer.PublicData()}, 1); err != nil {
+		r.t.Fatal(err)
+	}
+	return names
+}
+
+// newDelegatedKey generates a key and saves it for the delegated roles.
+func (r *Repository) newDelegatedKey(roles ...string) keys.Signer {
+	r.t.Helper()
+	signer, err := keys.GenerateEd25519Key()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	for _, role := range roles {
+		if err := r.store.SaveSigner(role, signer); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	return signer
+}
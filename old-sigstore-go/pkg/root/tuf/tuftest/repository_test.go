@@ -0,0 +1,229 @@
+//
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuftest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// bufferDestination is an in-memory client.Destination.
+type bufferDestination struct {
+	bytes.Buffer
+}
+
+func (b *bufferDestination) Delete() error {
+	b.Reset()
+	return nil
+}
+
+// newClient returns a go-tuf client trusting the current root.json of the
+// repository, and updating from its HTTP server.
+func newClient(t *testing.T, r *Repository) *client.Client {
+	t.Helper()
+	remote, err := client.HTTPRemoteStore(r.Serve(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(client.MemoryLocalStore(), remote)
+	if err := c.Init(r.Root()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func download(t *testing.T, c *client.Client, name string) []byte {
+	t.Helper()
+	var dest bufferDestination
+	if err := c.Download(name, &dest); err != nil {
+		t.Fatalf("Download(%s) unexpectedly returned an error: %v", name, err)
+	}
+	return dest.Bytes()
+}
+
+func TestRepository(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "consistent snapshot",
+			opts: []Option{WithConsistentSnapshot()},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			r := NewRepository(t, tc.opts...)
+			trustedRoot := NewTrustedRootJSON(t)
+			r.AddTrustedRoot(trustedRoot)
+			r.Publish()
+
+			c := newClient(t, r)
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+			b := download(t, c, TrustedRootTarget)
+			if !bytes.Equal(b, trustedRoot) {
+				t.Error("downloaded trusted root differs from the published one")
+			}
+			if _, err := root.NewTrustedRootFromJSON(b); err != nil {
+				t.Errorf("NewTrustedRootFromJSON unexpectedly returned an error: %v", err)
+			}
+
+			// Targets published later are served.
+			r.AddTarget("other.txt", []byte("other"), nil)
+			r.Publish()
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+			if b := download(t, c, "other.txt"); string(b) != "other" {
+				t.Errorf("unexpected target %q", b)
+			}
+		})
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	for _, role := range topLevelRoles {
+		role := role
+		t.Run(role, func(t *testing.T) {
+			t.Parallel()
+			r := NewRepository(t, WithConsistentSnapshot())
+			r.AddTrustedRoot(NewTrustedRootJSON(t))
+			r.Publish()
+			oldRoot := r.Root()
+			oldKeyIDs := r.rootMeta().Roles[role].KeyIDs
+
+			r.RotateKeys(role)
+			r.Publish()
+			newRoot := r.rootMeta()
+			if newRoot.Version != 2 {
+				t.Errorf("expected root version 2, got %d", newRoot.Version)
+			}
+			for _, id := range newRoot.Roles[role].KeyIDs {
+				for _, oldID := range oldKeyIDs {
+					if id == oldID {
+						t.Errorf("key %s of %s was not rotated", id, role)
+					}
+				}
+			}
+
+			// A client trusting the previous root.json follows the rotation.
+			remote, err := client.HTTPRemoteStore(r.Serve(), nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := client.NewClient(client.MemoryLocalStore(), remote)
+			if err := c.Init(oldRoot); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+			download(t, c, TrustedRootTarget)
+		})
+	}
+}
+
+func TestSetExpires(t *testing.T) {
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp", "delegated"} {
+		role := role
+		t.Run(role, func(t *testing.T) {
+			t.Parallel()
+			r := NewRepository(t)
+			r.Delegate("targets", "delegated", []string{"delegated/*"}, false)
+			r.AddTrustedRoot(NewTrustedRootJSON(t))
+			r.AddTarget("delegated/file.txt", []byte("delegated"), nil)
+			r.Publish()
+			c := newClient(t, r)
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+
+			r.SetExpires(role, time.Now().Add(-time.Hour))
+			_, err := c.Update()
+			if err == nil && role == "delegated" {
+				// Delegated metadata is downloaded when its targets are
+				// looked up.
+				_, err = c.Target("delegated/file.txt")
+			}
+			if err == nil {
+				t.Fatal("client accepted expired metadata")
+			}
+
+			r.SetExpires(role, time.Now().Add(time.Hour))
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+			download(t, c, "delegated/file.txt")
+
+			// The repository can be published again.
+			r.AddTarget("other.txt", []byte("other"), nil)
+			r.Publish()
+			if _, err := c.Update(); err != nil {
+				t.Fatalf("Update unexpectedly returned an error: %v", err)
+			}
+			download(t, c, "other.txt")
+		})
+	}
+}
+
+func TestDelegate(t *testing.T) {
+	t.Parallel()
+	r := NewRepository(t, WithConsistentSnapshot())
+	r.Delegate("targets", "team", []string{"team/*"}, true)
+	r.Delegate("team", "project", []string{"team/project-*"}, false)
+	bins := r.DelegateHashBins("bin-", 2)
+	if len(bins) != 4 {
+		t.Fatalf("expected 4 hash bins, got %d", len(bins))
+	}
+	r.AddTarget("team/project-a.txt", []byte("project"), nil)
+	r.AddTargetToRole("team", "team/b.txt", []byte("team"), nil)
+	r.AddTarget("c.txt", []byte("binned"), nil)
+	r.Publish()
+
+	c := newClient(t, r)
+	if _, err := c.Update(); err != nil {
+		t.Fatalf("Update unexpectedly returned an error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"team/project-a.txt": "project",
+		"team/b.txt":         "team",
+		"c.txt":              "binned",
+	} {
+		if b := download(t, c, name); string(b) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, b)
+		}
+	}
+	meta, err := r.Repo().GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range append([]string{"team", "project"}, bins...) {
+		if _, ok := meta[role+".json"]; !ok {
+			t.Errorf("%s.json was not published", role)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
 	rekor_v1 "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
@@ -40,10 +41,32 @@ type VerificationPayload struct {
 	LogID          string      `json:"logID"`
 }
 
+// TrustedLogs looks up the verifier of a trusted transparency log. It is
+// implemented by root.TrustedRoot.
+type TrustedLogs interface {
+	// TlogVerifier returns the verifier of the log with the hex-encoded
+	// logID whose key was valid at time t.
+	TlogVerifier(logID string, t time.Time) (signature.Verifier, error)
+}
+
+// VerifierMap is a set of trusted log verifiers indexed by hex-encoded log
+// ID, for callers that do not track validity periods.
+type VerifierMap map[string]signature.Verifier
+
+// TlogVerifier returns the verifier for logID, regardless of t.
+func (m VerifierMap) TlogVerifier(logID string, _ time.Time) (signature.Verifier, error) {
+	verifier, ok := m[logID]
+	if !ok {
+		return nil, fmt.Errorf("log %s not found", logID)
+	}
+	return verifier, nil
+}
+
 // VerifyTlogSET verifies the SignedEntryTimestamp (SET) for the given
-// TransparencyLogEntry using the trusted verifiers indexed by LogID.
+// TransparencyLogEntry using the log verifier that was valid at the entry's
+// integrated time.
 func VerifyTlogSET(ctx context.Context,
-	entry *rekor_v1.TransparencyLogEntry, trustedKeys map[string]signature.Verifier,
+	entry *rekor_v1.TransparencyLogEntry, trustedLogs TrustedLogs,
 ) error {
 	// Create the signed tlog verification payload.
 	payload, err := verificationPayload(entry)
@@ -67,9 +90,12 @@ func VerifyTlogSET(ctx context.Context,
 	if err != nil {
 		return fmt.Errorf("getting entry log ID: %w", err)
 	}
-	verifier, ok := trustedKeys[entryLogID]
-	if !ok {
-		return errors.New("rekor log public key not found for payload")
+	if trustedLogs == nil {
+		return errors.New("no trusted logs provided")
+	}
+	verifier, err := trustedLogs.TlogVerifier(entryLogID, time.Unix(entry.IntegratedTime, 0))
+	if err != nil {
+		return fmt.Errorf("rekor log public key not found for payload: %w", err)
 	}
 
 	// Extract the SET from the tlog entry
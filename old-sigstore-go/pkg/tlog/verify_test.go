@@ -72,14 +72,14 @@ func TestVerifyTlogSET(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	trustedKey := map[string]signature.Verifier{
+	trustedKey := VerifierMap{
 		logID: signer,
 	}
 
 	testCases := []struct {
 		name    string
 		entry   *rekor_v1.TransparencyLogEntry
-		keys    map[string]signature.Verifier
+		keys    TrustedLogs
 		wantErr bool
 	}{
 		{
@@ -90,7 +90,7 @@ func TestVerifyTlogSET(t *testing.T) {
 		{
 			name:    "fail: missing trusted tlog key",
 			entry:   tlogEntry,
-			keys:    map[string]signature.Verifier{},
+			keys:    VerifierMap{},
 			wantErr: true,
 		},
 		{